@@ -0,0 +1,172 @@
+package ftpgo
+
+import (
+	"context"
+	"errors"
+	"path"
+	"strings"
+)
+
+// TransferMode selects how the data of a file transfer is represented.
+type TransferMode int
+
+const (
+	// TransferDefault uses the TYPE of the session (see Type), ASCII when it is "A".
+	TransferDefault TransferMode = iota
+	// TransferBinary transfers the bytes unchanged with TYPE I.
+	TransferBinary
+	// TransferASCII transfers text with TYPE A, converting the CRLF line endings
+	// of the wire to LF on download and LF to CRLF on upload.
+	TransferASCII
+	// TransferAuto uses TransferASCII for the files whose extension is listed in
+	// ASCIIExtensions and TransferBinary otherwise.
+	TransferAuto
+)
+
+// ASCIIExtensions are the extensions of the files TransferAuto transfers in ASCII mode,
+// lowercase and with the leading dot.
+var ASCIIExtensions = []string{
+	".txt", ".text", ".csv", ".tsv", ".log", ".ini", ".cfg", ".conf",
+	".xml", ".json", ".yaml", ".yml", ".htm", ".html", ".css", ".js",
+	".md", ".sql", ".sh", ".bat", ".cmd", ".properties",
+}
+
+// errASCIIResume is returned by the transfers which would continue a file at an offset in ASCII mode.
+var errASCIIResume = errors.New("ASCII transfers cannot be resumed: the offsets differ from the local file")
+
+// WithTransferMode selects binary or ASCII mode for the transfer. The session is switched
+// with TYPE before the transfer when needed and back to its own TYPE afterwards
+// (TYPE A, the default of RFC 959, when Type was never called).
+// In ASCII mode the sizes of the local and remote files differ, so the transfer cannot be
+// resumed or checksummed and its size is not checked.
+func WithTransferMode(mode TransferMode) TransferOption {
+	return func(t *transferConfig) {
+		t.mode = mode
+	}
+}
+
+// IsASCIIFile tells if the extension of name is one of ASCIIExtensions.
+func IsASCIIFile(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	for _, e := range ASCIIExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// dataType returns the TYPE of a transfer of remote, "" for the one of the session.
+func (t *transferConfig) dataType(remote string) string {
+	switch t.mode {
+	case TransferBinary:
+		return "I"
+	case TransferASCII:
+		return "A"
+	case TransferAuto:
+		if IsASCIIFile(remote) {
+			return "A"
+		}
+		return "I"
+	}
+	return ""
+}
+
+// asciiTransfer tells if the transfer of remote converts the line endings.
+func (c *Ftp) asciiTransfer(t *transferConfig, remote string) bool {
+	if typ := t.dataType(remote); typ != "" {
+		return typ == "A"
+	}
+	return isASCIIType(c.transferType)
+}
+
+// switchType sends TYPE typ unless the session already uses it, and returns the
+// TYPE to restore after the transfer, "" when there is nothing to restore.
+func (c *Ftp) switchType(ctx context.Context, typ string) (string, error) {
+	if typ == "" || (c.transferType != "" && isASCIIType(c.transferType) == isASCIIType(typ)) {
+		return "", nil
+	}
+
+	if _, _, err := c.SendCmdContext(ctx, 200, "TYPE %s", typ); err != nil {
+		return "", err
+	}
+	restore := c.transferType
+	if restore == "" {
+		// the session was never switched, it still uses the default of RFC 959
+		restore = "A"
+	}
+	if restore == typ {
+		return "", nil
+	}
+	return restore, nil
+}
+
+// isASCIIType tells if the TYPE parameter is ASCII ("A" or "A N").
+func isASCIIType(typ string) bool {
+	return strings.HasPrefix(strings.ToUpper(strings.TrimSpace(typ)), "A")
+}
+
+// readASCII reads the data converting CRLF to LF. A CR ending a read is held back
+// until the next byte tells whether it ends a line; a lone CR is kept.
+func (r *FtpDataConnector) readASCII(buf []byte) (int, error) {
+	if len(buf) == 0 {
+		return 0, nil
+	}
+
+	for len(r.text) == 0 {
+		if r.textErr != nil {
+			return 0, r.textErr
+		}
+
+		// the first byte is room for the CR held back by the previous read
+		if cap(r.textBuf) < len(buf)+1 {
+			r.textBuf = make([]byte, len(buf)+1)
+		}
+		n, err := r.read(r.textBuf[1 : len(buf)+1])
+		data := r.textBuf[1 : n+1]
+		if r.cr {
+			r.textBuf[0] = '\r'
+			data = r.textBuf[:n+1]
+			r.cr = false
+		}
+
+		// converted in place, the output never gets ahead of the input
+		w := 0
+		for i := 0; i < len(data); i++ {
+			if data[i] == '\r' {
+				if i+1 == len(data) && err == nil {
+					r.cr = true
+					break
+				}
+				if i+1 < len(data) && data[i+1] == '\n' {
+					continue
+				}
+			}
+			data[w] = data[i]
+			w++
+		}
+		r.text, r.textErr = data[:w], err
+	}
+
+	n := copy(buf, r.text)
+	r.text = r.text[n:]
+	return n, nil
+}
+
+// writeASCII writes buf converting LF to CRLF; the line endings which are already CRLF are kept.
+func (r *FtpDataConnector) writeASCII(buf []byte) (int, error) {
+	text := r.textBuf[:0]
+	for _, b := range buf {
+		if b == '\n' && !r.cr {
+			text = append(text, '\r')
+		}
+		text = append(text, b)
+		r.cr = b == '\r'
+	}
+	r.textBuf = text
+
+	if _, err := r.writeData(text); err != nil {
+		return 0, err
+	}
+	return len(buf), nil
+}
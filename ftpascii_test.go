@@ -0,0 +1,187 @@
+package ftpgo
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// chunkConn is a data connection returning at most chunk bytes per Read,
+// and recording what is written to it.
+type chunkConn struct {
+	net.Conn
+	r       *bytes.Reader
+	chunk   int
+	written bytes.Buffer
+}
+
+func (c *chunkConn) Read(buf []byte) (int, error) {
+	if len(buf) > c.chunk {
+		buf = buf[:c.chunk]
+	}
+	return c.r.Read(buf)
+}
+
+func (c *chunkConn) Write(buf []byte) (int, error) {
+	return c.written.Write(buf)
+}
+
+var asciiTests = []struct {
+	wire  string
+	local string
+}{
+	{"", ""},
+	{"a\r\nb\r\n", "a\nb\n"},
+	{"\r\n\r\n\r\n", "\n\n\n"},
+	{"no line end", "no line end"},
+	// a CR which does not end a line is data
+	{"a\rb", "a\rb"},
+	{"a\r\r\nb", "a\r\nb"},
+	{"end\r", "end\r"},
+	{"\r", "\r"},
+	{"\r\r", "\r\r"},
+	{"x\r\n\r\ny\r\r\n\rz\r\n", "x\n\ny\r\n\rz\n"},
+}
+
+func TestReadASCII(t *testing.T) {
+	for _, tt := range asciiTests {
+		// every chunk size puts the CR and LF of some line ends in different reads
+		for chunk := 1; chunk <= len(tt.wire)+1; chunk++ {
+			for _, size := range []int{1, 2, 3, 64} {
+				conn := &chunkConn{r: bytes.NewReader([]byte(tt.wire)), chunk: chunk}
+				r := &FtpDataConnector{conn: conn, ctx: context.Background(), ascii: true}
+
+				var got bytes.Buffer
+				buf := make([]byte, size)
+				for {
+					n, err := r.Read(buf)
+					got.Write(buf[:n])
+					if err == io.EOF {
+						break
+					}
+					if err != nil {
+						t.Fatal(err)
+					}
+				}
+				if got.String() != tt.local {
+					t.Errorf("read %q in chunks of %d with a buffer of %d = %q, want %q",
+						tt.wire, chunk, size, got.String(), tt.local)
+				}
+			}
+		}
+	}
+}
+
+func TestWriteASCII(t *testing.T) {
+	tests := []struct {
+		local string
+		wire  string
+	}{
+		{"", ""},
+		{"a\nb\n", "a\r\nb\r\n"},
+		{"\n\n", "\r\n\r\n"},
+		// line ends which are already CRLF are kept
+		{"a\r\nb\n", "a\r\nb\r\n"},
+		{"a\rb", "a\rb"},
+		{"a\r\r\n", "a\r\r\n"},
+	}
+
+	for _, tt := range tests {
+		// split the data at every position, between a CR and its LF too
+		for split := 0; split <= len(tt.local); split++ {
+			conn := &chunkConn{}
+			w := &FtpDataConnector{conn: conn, ctx: context.Background(), ascii: true}
+			for _, part := range []string{tt.local[:split], tt.local[split:]} {
+				if n, err := w.Write([]byte(part)); err != nil || n != len(part) {
+					t.Fatalf("Write(%q) = %d, %v", part, n, err)
+				}
+			}
+			if got := conn.written.String(); got != tt.wire {
+				t.Errorf("write %q split at %d = %q, want %q", tt.local, split, got, tt.wire)
+			}
+		}
+	}
+}
+
+func TestTransferDataType(t *testing.T) {
+	tests := []struct {
+		mode   TransferMode
+		remote string
+		want   string
+	}{
+		{TransferDefault, "a.txt", ""},
+		{TransferBinary, "a.txt", "I"},
+		{TransferASCII, "a.bin", "A"},
+		{TransferAuto, "/dir/README.TXT", "A"},
+		{TransferAuto, "data.csv", "A"},
+		{TransferAuto, "image.png", "I"},
+		{TransferAuto, "noext", "I"},
+	}
+	for _, tt := range tests {
+		cfg := &transferConfig{mode: tt.mode}
+		if got := cfg.dataType(tt.remote); got != tt.want {
+			t.Errorf("dataType(%v, %q) = %q, want %q", tt.mode, tt.remote, got, tt.want)
+		}
+	}
+
+	for typ, want := range map[string]bool{"A": true, "a": true, "A N": true, " A": true, "I": false, "L 8": false, "": false} {
+		if got := isASCIIType(typ); got != want {
+			t.Errorf("isASCIIType(%q) = %v, want %v", typ, got, want)
+		}
+	}
+}
+
+// types returns the TYPE commands received since the first n commands.
+func (s *testServer) types(n int) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var types []string
+	for _, cmd := range s.cmds[n:] {
+		if strings.HasPrefix(cmd, "TYPE ") {
+			types = append(types, strings.TrimPrefix(cmd, "TYPE "))
+		}
+	}
+	return types
+}
+
+func TestTransferModeRestoresType(t *testing.T) {
+	s := newTestServer(t)
+	s.files["/f.txt"] = []byte("a\r\nb\r\n")
+	c := s.dial()
+	local := filepath.Join(t.TempDir(), "f.txt")
+
+	tests := []struct {
+		name   string
+		binary bool
+		mode   TransferMode
+		want   string
+	}{
+		// without Type the session uses the default TYPE A
+		{"binary in a new session", false, TransferBinary, "I A"},
+		{"ASCII in a new session", false, TransferASCII, "A"},
+		{"ASCII in a binary session", true, TransferASCII, "A I"},
+		{"binary in a binary session", true, TransferBinary, ""},
+		{"auto in a binary session", true, TransferAuto, "A I"},
+	}
+	for _, tt := range tests {
+		if tt.binary {
+			if err := c.Type("I"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		s.mu.Lock()
+		n := len(s.cmds)
+		s.mu.Unlock()
+
+		if err := c.RetrFile("/f.txt", local, WithTransferMode(tt.mode)); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := strings.Join(s.types(n), " "); got != tt.want {
+			t.Errorf("%s: sent TYPE %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package ftpgo
+
+import (
+	"context"
+	"errors"
+	"path"
+)
+
+// WithTempName makes StorFile upload to a temporary name in the same directory, made of
+// prefix, the file name and suffix, and rename it to the final name once SIZE confirms the
+// whole file arrived, so that nobody picks up a half-written file. The temporary file is
+// deleted when the upload fails. WithTempName(".", "") uploads to a hidden dot-file;
+// an empty prefix and suffix use the suffix ".part".
+func WithTempName(prefix, suffix string) TransferOption {
+	return func(t *transferConfig) {
+		if prefix == "" && suffix == "" {
+			suffix = ".part"
+		}
+		t.tempName, t.tempPrefix, t.tempSuffix = true, prefix, suffix
+	}
+}
+
+// tempPath returns the name to upload remote to.
+func (t *transferConfig) tempPath(remote string) string {
+	if !t.tempName {
+		return remote
+	}
+	dir, name := path.Split(remote)
+	return dir + t.tempPrefix + name + t.tempSuffix
+}
+
+// commitTemp renames the uploaded file temp to remote after checking that it has size bytes,
+// unless size is negative. When temp is remote only the size is checked.
+func (c *Ftp) commitTemp(temp, remote string, size int64) error {
+	if size >= 0 {
+		// without SIZE support the transfer reply is all there is
+		if err := c.verifyRemoteSize(temp, size); err != nil && !isUnsupported(err) {
+			return err
+		}
+	}
+	if temp == remote {
+		return nil
+	}
+
+	err := c.Rename(temp, remote)
+	if renameTargetExists(err) && c.isFile(remote) {
+		// some servers do not rename over an existing file; any other failure, e.g.
+		// a permission error, must not cost the published file
+		if derr := c.Delete(remote); derr == nil {
+			err = c.Rename(temp, remote)
+		}
+	}
+	return err
+}
+
+// renameTargetExists tells if a failed RNTO reply says that the target already exists.
+func renameTargetExists(err error) bool {
+	var e *FtpError
+	return errors.As(err, &e) && (e.Code == StatusFileUnavailable || e.Code == StatusBadFileName) &&
+		e.mentions("already exists", "file exists") && !e.mentions("not", "no such")
+}
+
+// isFile tells if the server confirms with MLST or SIZE that remote is a file.
+// A server supporting neither cannot tell, so false is returned.
+func (c *Ftp) isFile(remote string) bool {
+	if c.hasFeature("MLST") {
+		info, err := c.Mlst(remote)
+		return err == nil && !info.IsDir()
+	}
+	_, err := c.size(context.Background(), remote)
+	return err == nil
+}
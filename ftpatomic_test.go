@@ -0,0 +1,89 @@
+package ftpgo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCommitTempRenameFailure(t *testing.T) {
+	local := filepath.Join(t.TempDir(), "f")
+	if err := os.WriteFile(local, []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestServer(t)
+	s.files["/f"] = []byte("old")
+	s.replies["RNTO /f"] = "550 /f: Permission denied"
+	c := s.dial()
+
+	if err := c.StorFile(local, "/f", WithTempName("", "")); !hasCode(err, 550) {
+		t.Fatalf("StorFile = %v", err)
+	}
+	if s.sent("DELE /f") {
+		t.Fatal("published file deleted after a failed rename")
+	}
+	s.mu.Lock()
+	if string(s.files["/f"]) != "old" {
+		t.Fatalf("/f = %q", s.files["/f"])
+	}
+	s.replies["RNTO /f"] = "553 /f: File exists"
+	s.mu.Unlock()
+
+	// the target is only replaced when the server refuses to overwrite it
+	c.StorFile(local, "/f", WithTempName("", ""))
+	if !s.sent("DELE /f") {
+		t.Fatal("existing target not replaced")
+	}
+}
+
+func TestCommitTempTargetSurvives(t *testing.T) {
+	local := filepath.Join(t.TempDir(), "f")
+	if err := os.WriteFile(local, []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestServer(t)
+	s.files["/f"] = []byte("old")
+	s.replies["RNTO /f"] = "550 /f.part: does not exist"
+	s.replies["RNTO /g"] = "553 /g: File exists"
+	c := s.dial()
+
+	if err := c.StorFile(local, "/f", WithTempName("", "")); !hasCode(err, 550) {
+		t.Fatalf("StorFile = %v", err)
+	}
+	if data, ok := s.file("/f"); s.sent("DELE /f") || !ok || data != "old" {
+		t.Fatalf("target deleted after RNTO said something did not exist: %q, %v", data, ok)
+	}
+
+	// the server claims a target which SIZE does not find
+	if err := c.StorFile(local, "/g", WithTempName("", "")); !hasCode(err, 553) {
+		t.Fatalf("StorFile = %v", err)
+	}
+	if s.sent("DELE /g") {
+		t.Fatal("DELE sent for a target which does not exist")
+	}
+}
+
+func TestRenameTargetExists(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{newFtpError(550, "Rename failed: file exists"), true},
+		{newFtpError(553, "File exists"), true},
+		{newFtpError(550, "Permission denied"), false},
+		{newFtpError(553, "Bad file name"), false},
+		{newFtpError(450, "File exists"), false},
+		{newFtpError(550, "/f: does not exist"), false},
+		{newFtpError(550, "Directory /a does not exist"), false},
+		{newFtpError(553, "No such file exists"), false},
+		{newFtpError(553, "Target already exists"), true},
+	}
+	for _, tt := range tests {
+		if got := renameTargetExists(tt.err); got != tt.want {
+			t.Errorf("renameTargetExists(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package ftpgo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Charset converts between UTF-8 and the encoding a server uses for pathnames
+// and reply text, e.g. Shift_JIS or EUC-JP.
+type Charset interface {
+	// Encode converts a UTF-8 string to the encoding of the server.
+	Encode(s string) (string, error)
+	// Decode converts a string in the encoding of the server to UTF-8.
+	Decode(s string) (string, error)
+}
+
+// CharsetFuncs is a Charset made of two functions, e.g. the String methods of the
+// encoders and decoders of golang.org/x/text:
+//
+//	ftpgo.CharsetFuncs{
+//		EncodeFunc: japanese.ShiftJIS.NewEncoder().String,
+//		DecodeFunc: japanese.ShiftJIS.NewDecoder().String,
+//	}
+//
+// Such encoders keep state, so every session needs its own.
+type CharsetFuncs struct {
+	EncodeFunc func(s string) (string, error)
+	DecodeFunc func(s string) (string, error)
+}
+
+// Encode calls EncodeFunc.
+func (f CharsetFuncs) Encode(s string) (string, error) {
+	return f.EncodeFunc(s)
+}
+
+// Decode calls DecodeFunc.
+func (f CharsetFuncs) Decode(s string) (string, error) {
+	return f.DecodeFunc(s)
+}
+
+// Latin1 is the ISO-8859-1 Charset.
+var Latin1 Charset = latin1{}
+
+// latin1
+type latin1 struct{}
+
+// Encode
+func (latin1) Encode(s string) (string, error) {
+	var b strings.Builder
+	for _, r := range s {
+		if r > 0xff {
+			return "", fmt.Errorf("Character %q cannot be encoded in ISO-8859-1", r)
+		}
+		b.WriteByte(byte(r))
+	}
+	return b.String(), nil
+}
+
+// Decode
+func (latin1) Decode(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		b.WriteRune(rune(s[i]))
+	}
+	return b.String(), nil
+}
+
+// SetCharset sets the encoding of the server for the command arguments, the replies and
+// the directory listings; nil sends and reads UTF-8 unchanged. It is only used when the
+// server does not support UTF-8: after login OPTS UTF8 ON is sent when FEAT advertises UTF8
+// (RFC 2640), and the charset is ignored for such servers.
+func (c *Ftp) SetCharset(cs Charset) {
+	c.charset = cs
+}
+
+// UTF8 tells if the server advertises UTF8 and uses UTF-8 for pathnames.
+func (c *Ftp) UTF8() bool {
+	return c.utf8
+}
+
+// enableUTF8 sends OPTS UTF8 ON when the server advertises UTF8.
+func (c *Ftp) enableUTF8(ctx context.Context) {
+	c.utf8 = false
+	if !c.features.Has("UTF8") {
+		return
+	}
+	_, _, err := c.SendCmdContext(ctx, 200, "OPTS UTF8 ON")
+	// some servers always use UTF-8 and do not implement the command
+	c.utf8 = err == nil || isUnsupported(err)
+}
+
+// serverCharset returns the charset used to talk to the server, nil for UTF-8.
+func (c *Ftp) serverCharset() Charset {
+	if c.utf8 {
+		return nil
+	}
+	return c.charset
+}
+
+// encode converts a command line to the encoding of the server.
+func (c *Ftp) encode(line string) (string, error) {
+	cs := c.serverCharset()
+	if cs == nil {
+		return line, nil
+	}
+	encoded, err := cs.Encode(line)
+	if err != nil {
+		return "", fmt.Errorf("Cannot encode the command for the server: %w", err)
+	}
+	return encoded, nil
+}
+
+// decode converts text of the server to UTF-8. Text which does not decode is returned unchanged.
+func (c *Ftp) decode(text string) string {
+	cs := c.serverCharset()
+	if cs == nil {
+		return text
+	}
+	decoded, err := cs.Decode(text)
+	if err != nil || !utf8.ValidString(decoded) {
+		return text
+	}
+	return decoded
+}
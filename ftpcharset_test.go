@@ -0,0 +1,91 @@
+package ftpgo
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLatin1RoundTrip(t *testing.T) {
+	var all strings.Builder
+	for r := rune(0); r <= 0xff; r++ {
+		all.WriteRune(r)
+	}
+	encoded, err := Latin1.Encode(all.String())
+	if err != nil || len(encoded) != 256 {
+		t.Fatalf("Encode = %d bytes, %v", len(encoded), err)
+	}
+	if decoded, err := Latin1.Decode(encoded); err != nil || decoded != all.String() {
+		t.Fatalf("Decode = %q, %v", decoded, err)
+	}
+	if _, err := Latin1.Encode("日本"); err == nil {
+		t.Fatal("Encode accepted characters outside ISO-8859-1")
+	}
+}
+
+func TestCharsetSession(t *testing.T) {
+	s := newTestServer(t)
+	s.files["/caf\xe9.txt"] = []byte("data")
+	s.lists["/"] = "caf\xe9.txt\r\n"
+	c := s.dial()
+	c.SetCharset(Latin1)
+
+	if size, err := c.Size("/café.txt"); err != nil || size != 4 {
+		t.Fatalf("Size = %d, %v", size, err)
+	}
+	if !s.sent("SIZE /caf\xe9.txt") {
+		t.Fatal("name not sent in ISO-8859-1")
+	}
+	if names, err := c.Nlst("/"); err != nil || len(names) != 1 || names[0] != "café.txt" {
+		t.Fatalf("Nlst = %q, %v", names, err)
+	}
+	// the replies are decoded too
+	if _, err := c.Size("/naïve"); err == nil || !strings.Contains(err.Error(), "/naïve: No such file") {
+		t.Fatalf("Size(missing) = %v", err)
+	}
+
+	s.mu.Lock()
+	n := len(s.cmds)
+	s.mu.Unlock()
+	if _, err := c.Size("/日本"); err == nil || !strings.Contains(err.Error(), "Cannot encode") {
+		t.Fatalf("Size(unencodable) = %v", err)
+	}
+	s.mu.Lock()
+	sent := len(s.cmds) != n
+	s.mu.Unlock()
+	if sent {
+		t.Fatal("unencodable command sent")
+	}
+
+	// text which does not decode is kept as it is
+	c.SetCharset(CharsetFuncs{
+		EncodeFunc: Latin1.Encode,
+		DecodeFunc: func(string) (string, error) { return "", errors.New("cannot decode") },
+	})
+	if names, err := c.Nlst("/"); err != nil || len(names) != 1 || names[0] != "caf\xe9.txt" {
+		t.Fatalf("Nlst without decoding = %q, %v", names, err)
+	}
+}
+
+func TestCharsetUTF8Server(t *testing.T) {
+	s := newTestServer(t, "UTF8")
+	s.files["/café.txt"] = []byte("data")
+	c := s.dial()
+	c.SetCharset(Latin1)
+
+	if !s.sent("OPTS UTF8 ON") || !c.UTF8() {
+		t.Fatal("UTF-8 not enabled")
+	}
+	// the charset is ignored
+	if size, err := c.Size("/café.txt"); err != nil || size != 4 {
+		t.Fatalf("Size = %d, %v", size, err)
+	}
+
+	// a server which always uses UTF-8 may not implement OPTS UTF8
+	s.mu.Lock()
+	s.replies["OPTS"] = "502 not implemented"
+	s.mu.Unlock()
+	if c := s.dial(); !c.UTF8() {
+		t.Fatal("UTF-8 not used without OPTS UTF8")
+	}
+}
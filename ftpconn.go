@@ -1,29 +1,203 @@
 package ftpgo
 
-import "net"
+import (
+	"context"
+	"net"
+)
+
+// TransferOption configures a single file transfer, overriding the session settings.
+type TransferOption func(t *transferConfig)
+
+// transferConfig
+type transferConfig struct {
+	offset   uint64
+	start    int64
+	total    int64
+	progress ProgressFunc
+	limiter  *RateLimiter
+	// temporary name of an upload, see WithTempName
+	tempName   bool
+	tempPrefix string
+	tempSuffix string
+	// checksum verification, see WithVerifyHash
+	verifyHash bool
+	hashAlgo   string
+	// binary or ASCII, see WithTransferMode
+	mode TransferMode
+}
+
+// newTransfer returns the settings of a file transfer, starting from the session ones.
+func (c *Ftp) newTransfer(opts []TransferOption) *transferConfig {
+	t := &transferConfig{
+		total:    -1,
+		progress: c.progress,
+		limiter:  c.limiter,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
 
 //FtpDataConnector data connection
 type FtpDataConnector struct {
-	conn net.Conn
-	c    *Ftp
+	conn     net.Conn
+	c        *Ftp
+	ctx      context.Context
+	stop     func() bool
+	progress *progressTracker
+	limiter  *RateLimiter
+	// ASCII mode: line ending conversion, see readASCII and writeASCII
+	ascii   bool
+	cr      bool
+	text    []byte
+	textBuf []byte
+	textErr error
+	// TYPE of the session to restore after the transfer
+	restoreType string
 }
 
 //Read from data connection
 func (r *FtpDataConnector) Read(buf []byte) (int, error) {
-	return r.conn.Read(buf)
+	if r.ascii {
+		return r.readASCII(buf)
+	}
+	return r.read(buf)
+}
+
+// read
+func (r *FtpDataConnector) read(buf []byte) (int, error) {
+	if r.limiter != nil && len(buf) > r.limiter.Burst() {
+		buf = buf[:r.limiter.Burst()]
+	}
+
+	n, err := r.conn.Read(buf)
+	if n > 0 {
+		if r.limiter != nil {
+			if lerr := r.limiter.WaitN(r.ctx, n); lerr != nil && err == nil {
+				err = lerr
+			}
+		}
+		if r.progress != nil {
+			r.progress.add(n)
+		}
+	}
+	if err != nil && r.ctx.Err() != nil {
+		err = r.ctx.Err()
+	}
+	return n, err
 }
 
 //Write to data connection
 func (r *FtpDataConnector) Write(buf []byte) (int, error) {
-	return r.conn.Write(buf)
+	if r.ascii {
+		return r.writeASCII(buf)
+	}
+	return r.writeData(buf)
+}
+
+// writeData
+func (r *FtpDataConnector) writeData(buf []byte) (int, error) {
+	if r.limiter == nil {
+		return r.write(buf)
+	}
+
+	// a limited write is split into bursts
+	var written int
+	for len(buf) > 0 {
+		chunk := buf
+		if len(chunk) > r.limiter.Burst() {
+			chunk = chunk[:r.limiter.Burst()]
+		}
+		if err := r.limiter.WaitN(r.ctx, len(chunk)); err != nil {
+			return written, err
+		}
+		n, err := r.write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		buf = buf[n:]
+	}
+	return written, nil
+}
+
+// write
+func (r *FtpDataConnector) write(buf []byte) (int, error) {
+	n, err := r.conn.Write(buf)
+	if n > 0 && r.progress != nil {
+		r.progress.add(n)
+	}
+	if err != nil && r.ctx.Err() != nil {
+		err = r.ctx.Err()
+	}
+	return n, err
 }
 
 //Close to data connection
 func (r *FtpDataConnector) Close() error {
+	if r.stop == nil {
+		return nil
+	}
+
 	err := r.conn.Close()
 	_, _, err2 := r.c.getResponse(226)
-	if err2 != nil {
+	fired := r.stop()
+	r.stop = nil
+	if fired && err2 != nil && !isReply(err2) {
+		// the context interrupted the read of the reply, which may tell the transfer completed
+		err2 = r.c.readTransferReply()
+	}
+
+	switch {
+	case err2 == nil:
+		// the transfer completed, even if the context is done by now
+	case isReply(err2) && r.ctx.Err() != nil:
+		// the server ended the transfer cut short by the context, there is nothing to abort
+		r.resetType()
+		return r.ctx.Err()
+	case fired || r.ctx.Err() != nil:
+		// no reply: the transfer interrupted by the context is aborted on the control connection;
+		// the 226 read by abortTransfer may be the reply to the finished transfer,
+		// the reply to ABOR is then still pending
+		if r.c.abortTransfer() == nil && r.c.resync() == nil {
+			r.resetType()
+		}
+		return r.ctx.Err()
+	default:
 		err = err2
 	}
+	if rerr := r.resetType(); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// Abort stops the transfer before the end of the data, e.g. when only a part of
+// the file is needed: the data connection is closed and ABOR is sent on the control connection.
+func (r *FtpDataConnector) Abort() error {
+	if r.stop == nil {
+		return nil
+	}
+
+	r.conn.Close()
+	r.stop()
+	r.stop = nil
+	if err := r.c.abortTransfer(); err != nil {
+		return err
+	}
+	if err := r.c.resync(); err != nil {
+		return err
+	}
+	return r.resetType()
+}
+
+// resetType switches the session back to its TYPE after a transfer in another mode.
+func (r *FtpDataConnector) resetType() error {
+	if r.restoreType == "" {
+		return nil
+	}
+	_, _, err := r.c.SendCmd(200, "TYPE %s", r.restoreType)
+	r.restoreType = ""
 	return err
 }
@@ -0,0 +1,103 @@
+package ftpgo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestCloseCompletedTransfer(t *testing.T) {
+	s := newTestServer(t)
+	s.files["/f"] = []byte("0123456789")
+	s.delay = 20 * time.Millisecond
+	c := s.dial()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r, err := c.RetrRequestContext(ctx, "/f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(r); err != nil {
+		t.Fatal(err)
+	}
+	// the transfer is complete before the context is done
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close = %v", err)
+	}
+	if s.sent("ABOR") {
+		t.Fatal("ABOR sent for a completed transfer")
+	}
+	if size, err := c.Size("/f"); err != nil || size != 10 {
+		t.Fatalf("Size = %d, %v", size, err)
+	}
+}
+
+func TestCancelTransfer(t *testing.T) {
+	s := newTestServer(t)
+	// larger than the socket buffers, so the transfer is still running when cancelled
+	s.files["/big"] = bytes.Repeat([]byte("x"), 16<<20)
+	s.files["/f"] = []byte("0123456789")
+	c := s.dial()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r, err := c.RetrRequestContext(ctx, "/big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(r, make([]byte, 1024)); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, err := io.ReadAll(r); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Read after cancel = %v", err)
+	}
+	if err := r.Close(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Close = %v", err)
+	}
+	if size, err := c.Size("/f"); err != nil || size != 10 {
+		t.Fatalf("Size = %d, %v", size, err)
+	}
+
+	// a context done before the transfer starts it
+	if _, err := c.RetrRequestContext(ctx, "/f"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("RetrRequestContext = %v", err)
+	}
+	if _, _, err := c.SendCmdContext(ctx, 200, "NOOP"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("SendCmdContext = %v", err)
+	}
+}
+
+func TestConnectContext(t *testing.T) {
+	// a server which never sends its greeting
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := FtpConnectContext(ctx, ln.Addr().String(), 5*time.Second); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("FtpConnectContext = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("FtpConnectContext returned after %v", elapsed)
+	}
+}
@@ -0,0 +1,210 @@
+package ftpgo
+
+import (
+	"errors"
+	"io/fs"
+	"net/textproto"
+	"strings"
+)
+
+// FTP reply codes (RFC 959, RFC 2228, RFC 2428, RFC 3659).
+const (
+	StatusRestartMarker            = 110
+	StatusServiceReadyIn           = 120
+	StatusAlreadyOpen              = 125
+	StatusAboutToSend              = 150
+	StatusCommandOK                = 200
+	StatusCommandNotImplemented    = 202
+	StatusSystemStatus             = 211
+	StatusDirectoryStatus          = 212
+	StatusFileStatus               = 213
+	StatusHelpMessage              = 214
+	StatusSystemType               = 215
+	StatusReady                    = 220
+	StatusClosing                  = 221
+	StatusDataConnectionOpen       = 225
+	StatusClosingDataConnection    = 226
+	StatusPassiveMode              = 227
+	StatusLongPassiveMode          = 228
+	StatusExtendedPassiveMode      = 229
+	StatusLoggedIn                 = 230
+	StatusLoggedOut                = 231
+	StatusLogoutAck                = 232
+	StatusAuthOK                   = 234
+	StatusRequestedFileActionOK    = 250
+	StatusPathCreated              = 257
+	StatusUserOK                   = 331
+	StatusLoginNeedAccount         = 332
+	StatusRequestFilePending       = 350
+	StatusNotAvailable             = 421
+	StatusCanNotOpenDataConnection = 425
+	StatusTransferAborted          = 426
+	StatusInvalidCredentials       = 430
+	StatusHostUnavailable          = 434
+	StatusFileActionIgnored        = 450
+	StatusActionAborted            = 451
+	StatusInsufficientStorage      = 452
+	StatusBadCommand               = 500
+	StatusBadArguments             = 501
+	StatusNotImplemented           = 502
+	StatusBadSequence              = 503
+	StatusNotImplementedParameter  = 504
+	StatusNotLoggedIn              = 530
+	StatusStorNeedAccount          = 532
+	StatusProtectionLevelDenied    = 534
+	StatusRequestDeniedPolicy      = 535
+	StatusFileUnavailable          = 550
+	StatusPageTypeUnknown          = 551
+	StatusExceededStorage          = 552
+	StatusBadFileName              = 553
+)
+
+// Errors matched by a *FtpError with errors.Is, according to its reply code.
+var (
+	// ErrNotFound matches 550/450 replies about a missing file or directory.
+	ErrNotFound = errors.New("File not found")
+	// ErrPermission matches replies refusing access to a file.
+	ErrPermission = errors.New("Permission denied")
+	// ErrAuthFailed matches replies rejecting the login.
+	ErrAuthFailed = errors.New("Authentication failed")
+	// ErrTransient matches every 4xx reply, which may succeed when retried.
+	ErrTransient = errors.New("Transient failure")
+	// ErrPermanent matches every 5xx reply.
+	ErrPermanent = errors.New("Permanent failure")
+	// ErrTransferAborted matches replies about an interrupted data transfer.
+	ErrTransferAborted = errors.New("Transfer aborted")
+	// ErrUnsupported matches replies to commands the server does not implement.
+	ErrUnsupported = errors.New("Command not supported")
+)
+
+// FtpError is a failure reply of the server. It wraps the original *textproto.Error,
+// and errors.Is reports which of the Err* values (and fs.ErrNotExist, fs.ErrExist,
+// fs.ErrPermission) it corresponds to.
+type FtpError struct {
+	Code int
+	Msg  string
+	err  *textproto.Error
+}
+
+// newFtpError
+func newFtpError(code int, msg string) *FtpError {
+	return &FtpError{Code: code, Msg: msg, err: &textproto.Error{Code: code, Msg: msg}}
+}
+
+// Error returns the reply as "code message".
+func (e *FtpError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the *textproto.Error of the reply.
+func (e *FtpError) Unwrap() error {
+	return e.err
+}
+
+// Temporary reports whether the reply is a transient (4xx) failure.
+func (e *FtpError) Temporary() bool {
+	return e.Code >= 400 && e.Code < 500
+}
+
+// Permanent reports whether the reply is a permanent (5xx) failure.
+func (e *FtpError) Permanent() bool {
+	return e.Code >= 500 && e.Code < 600
+}
+
+// Is reports whether the reply corresponds to target. The reply code decides, but for 550
+// and 450, which servers send for most failures: a message saying the access is denied or
+// the file already exists is fs.ErrPermission or fs.ErrExist, anything else is ErrNotFound.
+func (e *FtpError) Is(target error) bool {
+	switch target {
+	case ErrTransient:
+		return e.Temporary()
+	case ErrPermanent:
+		return e.Permanent()
+	case ErrAuthFailed:
+		return e.Code == StatusNotLoggedIn || e.Code == StatusInvalidCredentials || e.Code == StatusLoginNeedAccount
+	case ErrTransferAborted:
+		return e.Code == StatusTransferAborted || e.Code == StatusActionAborted
+	case ErrUnsupported:
+		return e.Code == StatusBadCommand || e.Code == StatusNotImplemented ||
+			e.Code == StatusNotImplementedParameter || e.Code == StatusCommandNotImplemented
+	case ErrPermission, fs.ErrPermission:
+		return e.isPermission()
+	case fs.ErrExist:
+		return e.isExist()
+	case ErrNotFound, fs.ErrNotExist:
+		return (e.Code == StatusFileUnavailable || e.Code == StatusFileActionIgnored) &&
+			!e.isPermission() && !e.isExist()
+	}
+	return false
+}
+
+// isPermission
+func (e *FtpError) isPermission() bool {
+	switch e.Code {
+	case StatusStorNeedAccount, StatusBadFileName:
+		return true
+	case StatusFileUnavailable, StatusFileActionIgnored:
+		// servers use 550 for most failures, so only its message tells them apart
+		return e.mentions("permission denied", "access denied", "access is denied", "not permitted")
+	}
+	return false
+}
+
+// isExist
+func (e *FtpError) isExist() bool {
+	switch e.Code {
+	case 521: // "already exists", used by some servers for MKD
+		return true
+	case StatusFileUnavailable:
+		return e.mentions("already exists", "file exists", "directory exists") && !e.mentionsMissing()
+	}
+	return false
+}
+
+// mentionsMissing reports whether the message says the file does not exist.
+func (e *FtpError) mentionsMissing() bool {
+	return e.mentions("no such", "not found", "not exist", "n't exist")
+}
+
+// mentions reports whether the message contains one of the phrases, ignoring case.
+func (e *FtpError) mentions(phrases ...string) bool {
+	msg := strings.ToLower(e.Msg)
+	for _, phrase := range phrases {
+		if strings.Contains(msg, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// replyError converts a failure reply read from the control connection to a *FtpError.
+func replyError(err error) error {
+	if e, ok := err.(*textproto.Error); ok {
+		return &FtpError{Code: e.Code, Msg: e.Msg, err: e}
+	}
+	return err
+}
+
+// hasCode reports whether err is a server reply with one of the given codes.
+func hasCode(err error, codes ...int) bool {
+	var e *FtpError
+	if errors.As(err, &e) {
+		for _, code := range codes {
+			if e.Code == code {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isReply reports whether err is a reply of the server, as opposed to a failure of the connection.
+func isReply(err error) bool {
+	var e *FtpError
+	return errors.As(err, &e)
+}
+
+// isUnsupported reports whether err is a 500/502 reply, which servers send for commands they do not implement.
+func isUnsupported(err error) bool {
+	return hasCode(err, StatusBadCommand, StatusNotImplemented)
+}
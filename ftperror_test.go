@@ -0,0 +1,93 @@
+package ftpgo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/textproto"
+	"testing"
+)
+
+func TestFtpErrorIs(t *testing.T) {
+	targets := map[string]error{
+		"ErrTransient":       ErrTransient,
+		"ErrPermanent":       ErrPermanent,
+		"ErrAuthFailed":      ErrAuthFailed,
+		"ErrTransferAborted": ErrTransferAborted,
+		"ErrUnsupported":     ErrUnsupported,
+		"ErrPermission":      ErrPermission,
+		"fs.ErrPermission":   fs.ErrPermission,
+		"fs.ErrExist":        fs.ErrExist,
+		"ErrNotFound":        ErrNotFound,
+		"fs.ErrNotExist":     fs.ErrNotExist,
+	}
+	tests := []struct {
+		code int
+		msg  string
+		is   []string
+	}{
+		{202, "Command not implemented, superfluous at this site", []string{"ErrUnsupported"}},
+		{421, "Service not available", []string{"ErrTransient"}},
+		{425, "Can't open data connection", []string{"ErrTransient"}},
+		{426, "Connection closed; transfer aborted", []string{"ErrTransient", "ErrTransferAborted"}},
+		{430, "Invalid username or password", []string{"ErrTransient", "ErrAuthFailed"}},
+		{450, "/a.txt: No such file", []string{"ErrTransient", "ErrNotFound", "fs.ErrNotExist"}},
+		{450, "/a.txt: Permission denied", []string{"ErrTransient", "ErrPermission", "fs.ErrPermission"}},
+		{451, "Local error in processing", []string{"ErrTransient", "ErrTransferAborted"}},
+		{500, "Syntax error", []string{"ErrPermanent", "ErrUnsupported"}},
+		{501, "Syntax error in parameters", []string{"ErrPermanent"}},
+		{502, "Command not implemented", []string{"ErrPermanent", "ErrUnsupported"}},
+		{504, "Command not implemented for that parameter", []string{"ErrPermanent", "ErrUnsupported"}},
+		{521, "\"/pub\" directory already exists", []string{"ErrPermanent", "fs.ErrExist"}},
+		{530, "Login incorrect", []string{"ErrPermanent", "ErrAuthFailed"}},
+		{532, "Need account for storing files", []string{"ErrPermanent", "ErrPermission", "fs.ErrPermission"}},
+		{550, "/a.txt: No such file or directory", []string{"ErrPermanent", "ErrNotFound", "fs.ErrNotExist"}},
+		{550, "Requested action not taken", []string{"ErrPermanent", "ErrNotFound", "fs.ErrNotExist"}},
+		{550, "/a.txt: PERMISSION DENIED", []string{"ErrPermanent", "ErrPermission", "fs.ErrPermission"}},
+		{550, "/pub: File exists", []string{"ErrPermanent", "fs.ErrExist"}},
+		{553, "File name not allowed", []string{"ErrPermanent", "ErrPermission", "fs.ErrPermission"}},
+		{553, "Could not create file.", []string{"ErrPermanent", "ErrPermission", "fs.ErrPermission"}},
+		// wording of other servers
+		{550, "Access is denied.", []string{"ErrPermanent", "ErrPermission", "fs.ErrPermission"}},
+		{550, "/a.txt: Operation not permitted", []string{"ErrPermanent", "ErrPermission", "fs.ErrPermission"}},
+		{550, "Could not delete /a.txt", []string{"ErrPermanent", "ErrNotFound", "fs.ErrNotExist"}},
+		{550, "File not found", []string{"ErrPermanent", "ErrNotFound", "fs.ErrNotExist"}},
+		{550, "/a.txt does not exist", []string{"ErrPermanent", "ErrNotFound", "fs.ErrNotExist"}},
+		{550, "Rename failed: target directory doesn't exist", []string{"ErrPermanent", "ErrNotFound", "fs.ErrNotExist"}},
+		{550, "Can't create directory: File exists", []string{"ErrPermanent", "fs.ErrExist"}},
+		{550, "Directory already exists", []string{"ErrPermanent", "fs.ErrExist"}},
+	}
+	for _, tt := range tests {
+		is := map[string]bool{}
+		for _, name := range tt.is {
+			is[name] = true
+		}
+		err := fmt.Errorf("Cannot do it: %w", newFtpError(tt.code, tt.msg))
+		for name, target := range targets {
+			if got := errors.Is(err, target); got != is[name] {
+				t.Errorf("errors.Is(%d %s, %s) = %v", tt.code, tt.msg, name, got)
+			}
+		}
+	}
+}
+
+func TestReplyError(t *testing.T) {
+	reply := &textproto.Error{Code: 550, Msg: "/a.txt: No such file"}
+	err := replyError(reply)
+	var ftpErr *FtpError
+	if !errors.As(err, &ftpErr) || ftpErr.Code != 550 || ftpErr.Msg != "/a.txt: No such file" {
+		t.Fatalf("replyError = %#v", err)
+	}
+	if err.Error() != reply.Error() || !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("replyError = %v", err)
+	}
+	var protoErr *textproto.Error
+	if !errors.As(err, &protoErr) || protoErr != reply {
+		t.Fatalf("replyError does not wrap the *textproto.Error: %v", err)
+	}
+
+	if err := replyError(io.EOF); err != io.EOF {
+		t.Fatalf("replyError(io.EOF) = %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package ftpgo
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// Features is the set of extensions advertised by a server in its FEAT reply,
+// keyed by upper-case feature name with the rest of the line as its parameters.
+type Features map[string]string
+
+// Has reports whether the named feature (e.g. "MLST", "EPSV") is supported.
+func (f Features) Has(name string) bool {
+	_, ok := f[strings.ToUpper(name)]
+	return ok
+}
+
+// Param returns the parameters of the named feature, e.g. "STREAM" for REST.
+func (f Features) Param(name string) string {
+	return f[strings.ToUpper(name)]
+}
+
+// Params returns the ';' separated parameters of the named feature,
+// e.g. the fact list of MLST or the mechanisms of AUTH.
+func (f Features) Params(name string) []string {
+	var params []string
+	for _, param := range strings.Split(f.Param(name), ";") {
+		if param = strings.TrimSpace(param); param != "" {
+			params = append(params, param)
+		}
+	}
+	return params
+}
+
+// HasParam reports whether the named feature lists param among its parameters (case-insensitive).
+// A trailing '*', which marks the facts or algorithms currently enabled, is ignored.
+func (f Features) HasParam(name, param string) bool {
+	for _, p := range f.Params(name) {
+		for _, word := range strings.Fields(p) {
+			if strings.EqualFold(strings.TrimSuffix(word, "*"), param) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MlstFacts returns the facts the server supports in MLST/MLSD listings,
+// without the '*' markers of the enabled ones.
+func (f Features) MlstFacts() []string {
+	var facts []string
+	for _, fact := range f.Params("MLST") {
+		facts = append(facts, strings.TrimSuffix(fact, "*"))
+	}
+	return facts
+}
+
+// Names returns the advertised feature names in sorted order.
+func (f Features) Names() []string {
+	names := make([]string, 0, len(f))
+	for name := range f {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Feat issues a FEAT FTP command and returns the extensions supported by the server.
+// The result is kept by the session and used to choose commands automatically.
+// Login issues FEAT itself, so this is only needed to refresh the set.
+func (c *Ftp) Feat() (Features, error) {
+	return c.featContext(context.Background())
+}
+
+// featContext
+func (c *Ftp) featContext(ctx context.Context) (Features, error) {
+	_, msg, err := c.SendCmdContext(ctx, 211, "FEAT")
+	if err != nil {
+		return nil, err
+	}
+
+	features := parseFeat(msg)
+	c.features = features
+	return features, nil
+}
+
+// Features returns the extensions supported by the server, issuing FEAT the first time.
+// A server that does not implement FEAT has an empty feature set.
+func (c *Ftp) Features() Features {
+	if c.features == nil {
+		if _, err := c.Feat(); err != nil {
+			c.features = Features{}
+		}
+	}
+	return c.features
+}
+
+// negotiate refreshes the feature set after login; the capabilities often differ from before.
+// UTF-8 pathnames are then enabled when the server supports them.
+func (c *Ftp) negotiate(ctx context.Context) {
+	if _, err := c.featContext(ctx); err != nil {
+		c.features = Features{}
+	}
+	c.enableUTF8(ctx)
+}
+
+// hasFeature reports whether the server advertises the named extension.
+func (c *Ftp) hasFeature(name string) bool {
+	return c.Features().Has(name)
+}
+
+// parseFeat
+func parseFeat(msg string) Features {
+	// 211-Features:
+	//  MDTM
+	//  MLST type*;size*;modify*;
+	// 211 End
+	features := Features{}
+	for _, line := range strings.Split(msg, "\n")[1:] {
+		// feature lines begin with a space, the closing line does not
+		if !strings.HasPrefix(line, " ") {
+			continue
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		name, param := line, ""
+		if space := strings.Index(line, " "); space != -1 {
+			name, param = line[:space], strings.TrimSpace(line[space+1:])
+		}
+		name = strings.ToUpper(name)
+
+		// some servers list a feature once per parameter, e.g. "AUTH TLS" and "AUTH SSL"
+		if prev, ok := features[name]; ok && prev != "" && param != "" {
+			param = prev + ";" + param
+		}
+		features[name] = param
+	}
+	return features
+}
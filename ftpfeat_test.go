@@ -0,0 +1,87 @@
+package ftpgo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFeat(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want Features
+	}{
+		{"Features:\nEnd", Features{}},
+		{"No features", Features{}},
+		{
+			"Features:\n MDTM\n REST STREAM\n SIZE\n MLST type*;size*;modify*;\n UTF8\nEnd",
+			Features{"MDTM": "", "REST": "STREAM", "SIZE": "", "MLST": "type*;size*;modify*;", "UTF8": ""},
+		},
+		// the names are case-insensitive, the parameters are kept
+		{"Features:\n mdtm\n Lang EN*;FR\nEnd", Features{"MDTM": "", "LANG": "EN*;FR"}},
+		// one line per parameter
+		{"Extensions supported:\n AUTH TLS\n AUTH SSL\n PBSZ\nEnd", Features{"AUTH": "TLS;SSL", "PBSZ": ""}},
+		// lines without the leading space are not features
+		{"Features:\nEPSV\n EPRT\n \n HASH  SHA-256*;MD5 \nEnd.", Features{"EPRT": "", "HASH": "SHA-256*;MD5"}},
+	}
+	for _, tt := range tests {
+		if got := parseFeat(tt.msg); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseFeat(%q) = %v, want %v", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestFeatures(t *testing.T) {
+	f := parseFeat("Features:\n MLST Type*;Size*;Modify;UNIX.mode\n HASH SHA-1;SHA-256*;MD5\n AUTH TLS\n AUTH SSL\n EPSV\nEnd")
+
+	for name, want := range map[string]bool{"mlst": true, "EPSV": true, "Hash": true, "MLSD": false, "": false} {
+		if got := f.Has(name); got != want {
+			t.Errorf("Has(%q) = %v", name, got)
+		}
+	}
+	if got := f.Param("auth"); got != "TLS;SSL" {
+		t.Errorf("Param(auth) = %q", got)
+	}
+	if got := f.Params("HASH"); !reflect.DeepEqual(got, []string{"SHA-1", "SHA-256*", "MD5"}) {
+		t.Errorf("Params(HASH) = %q", got)
+	}
+	if got := f.Params("EPSV"); got != nil {
+		t.Errorf("Params(EPSV) = %q", got)
+	}
+	if got := f.MlstFacts(); !reflect.DeepEqual(got, []string{"Type", "Size", "Modify", "UNIX.mode"}) {
+		t.Errorf("MlstFacts = %q", got)
+	}
+	if got := f.Names(); !reflect.DeepEqual(got, []string{"AUTH", "EPSV", "HASH", "MLST"}) {
+		t.Errorf("Names = %q", got)
+	}
+
+	tests := []struct {
+		name, param string
+		want        bool
+	}{
+		{"HASH", "sha-256", true},
+		{"HASH", "SHA-256*", false},
+		{"HASH", "SHA-512", false},
+		{"MLST", "modify", true},
+		{"MLST", "unix.mode", true},
+		{"AUTH", "SSL", true},
+		{"EPSV", "", false},
+		{"MLSD", "type", false},
+	}
+	for _, tt := range tests {
+		if got := f.HasParam(tt.name, tt.param); got != tt.want {
+			t.Errorf("HasParam(%q, %q) = %v", tt.name, tt.param, got)
+		}
+	}
+}
+
+func TestFeaturesFromServer(t *testing.T) {
+	c := newTestServer(t, "MDTM", "REST STREAM", "UTF8").dial()
+	if f := c.Features(); !f.Has("MDTM") || f.Param("REST") != "STREAM" || !c.UTF8() {
+		t.Fatalf("Features = %v, UTF8 = %v", f, c.UTF8())
+	}
+
+	c = newTestServer(t).dial()
+	if f := c.Features(); f == nil || len(f) != 0 {
+		t.Fatalf("Features without FEAT = %v", f)
+	}
+}
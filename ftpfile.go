@@ -3,6 +3,7 @@ package ftpgo
 import (
 	"bufio"
 	"errors"
+	"io/fs"
 	"os"
 	"path"
 	"path/filepath"
@@ -17,6 +18,16 @@ func FileMatchList(dir string, regexp string) (matches []string) {
 	return files
 }
 
+//FileMatch reports whether name matches one of the patterns of FileMatchList
+func FileMatch(name string, patterns ...string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
 //ReadFileList get row data list
 func ReadFileList(filename string) []string {
 	fp, err := os.Open(filename)
@@ -73,11 +84,16 @@ func SaveFile(filename string, text []byte) error {
 
 //FtpFile struct
 type FtpFile struct {
-	name  string
-	size  int64
-	mode  os.FileMode
-	mtime time.Time
-	raw   string
+	name   string
+	size   int64
+	mode   os.FileMode
+	mtime  time.Time
+	raw    string
+	perm   string
+	unique string
+	owner  string
+	target string
+	facts  map[string]string
 }
 
 //Name get filename
@@ -110,6 +126,41 @@ func (f *FtpFile) Sys() interface{} {
 	return f.raw
 }
 
+//Type get the type bits of filemode, for fs.DirEntry
+func (f *FtpFile) Type() fs.FileMode {
+	return f.mode.Type()
+}
+
+//Info get the FtpFile itself, for fs.DirEntry
+func (f *FtpFile) Info() (fs.FileInfo, error) {
+	return f, nil
+}
+
+//Perm get MLSx perm fact (e.g. "adfrw")
+func (f *FtpFile) Perm() string {
+	return f.perm
+}
+
+//Unique get MLSx unique fact, which identifies the file on the server
+func (f *FtpFile) Unique() string {
+	return f.unique
+}
+
+//Owner get MLSx unix.owner fact
+func (f *FtpFile) Owner() string {
+	return f.owner
+}
+
+//Target get the target of a symbolic link, or "" when the listing did not include it
+func (f *FtpFile) Target() string {
+	return f.target
+}
+
+//Fact get the named MLSx fact, or "" when the listing did not include it
+func (f *FtpFile) Fact(name string) string {
+	return f.facts[strings.ToLower(name)]
+}
+
 var errUnknownFormat = errors.New("Unknown format")
 
 var formatParsers = []func(line string) (*FtpFile, error){
@@ -143,6 +194,9 @@ func ParseDosDateTime(input string) (dateTime time.Time, err error) {
 
 //ParseDosFormat file time parse for DOS
 func ParseDosFormat(input string) (*FtpFile, error) {
+	if len(input) < 17 {
+		return nil, errUnknownFormat
+	}
 	value := input[:17]
 	mtime, err := ParseDosDateTime(value)
 	if err != nil {
@@ -191,7 +245,7 @@ func ParseUnixFormat(input string) (*FtpFile, error) {
 	var mtime time.Time
 
 	fields := strings.Fields(input)
-	if len(fields) < 9 {
+	if len(fields) < 9 || len(fields[0]) < 10 {
 		return nil, errUnknownFormat
 	}
 
@@ -239,13 +293,20 @@ func ParseUnixFormat(input string) (*FtpFile, error) {
 
 	// name
 	name = strings.Join(fields[8:], " ")
+	var target string
+	if mode&os.ModeSymlink != 0 {
+		if i := strings.Index(name, " -> "); i != -1 {
+			name, target = name[:i], name[i+4:]
+		}
+	}
 
 	f := &FtpFile{
-		name:  name,
-		size:  int64(size),
-		mode:  mode,
-		mtime: mtime,
-		raw:   input,
+		name:   name,
+		size:   int64(size),
+		mode:   mode,
+		mtime:  mtime,
+		raw:    input,
+		target: target,
 	}
 
 	return f, nil
@@ -267,3 +328,88 @@ func ParseDateTime(fields []string) (mtime time.Time, err error) {
 	mtime, err = time.Parse("_2 Jan 06 15:04 MST", value)
 	return
 }
+
+//ParseMlsxFormat file parse for MLSD/MLST fact lines (RFC 3659)
+func ParseMlsxFormat(input string) (*FtpFile, error) {
+	// facts are "name=value;" pairs followed by a single space and the pathname
+	space := strings.Index(input, " ")
+	if space == -1 || !strings.Contains(input[:space], "=") {
+		return nil, errUnknownFormat
+	}
+
+	f := &FtpFile{
+		name:  input[space+1:],
+		raw:   input,
+		facts: make(map[string]string),
+	}
+
+	for _, fact := range strings.Split(input[:space], ";") {
+		if fact == "" {
+			continue
+		}
+		eq := strings.Index(fact, "=")
+		if eq == -1 {
+			return nil, errUnknownFormat
+		}
+		key := strings.ToLower(fact[:eq])
+		value := fact[eq+1:]
+		f.facts[key] = value
+
+		switch key {
+		case "type":
+			switch strings.ToLower(value) {
+			case "dir", "cdir", "pdir":
+				f.mode |= os.ModeDir
+			case "os.unix=symlink":
+				f.mode |= os.ModeSymlink
+			default:
+				if strings.HasPrefix(strings.ToLower(value), "os.unix=slink") {
+					f.mode |= os.ModeSymlink
+					// OS.unix=slink:/target
+					if i := strings.Index(value, ":"); i != -1 {
+						f.target = value[i+1:]
+					}
+				}
+			}
+		case "size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			f.size = size
+		case "modify":
+			mtime, err := parseTimeVal(value)
+			if err != nil {
+				return nil, err
+			}
+			f.mtime = mtime
+		case "perm":
+			f.perm = value
+		case "unique":
+			f.unique = value
+		case "unix.mode":
+			mode, err := strconv.ParseUint(value, 8, 32)
+			if err != nil {
+				return nil, err
+			}
+			f.mode |= os.FileMode(mode) & os.ModePerm
+		case "unix.owner":
+			f.owner = value
+		}
+	}
+
+	return f, nil
+}
+
+//parseTimeVal parse a RFC 3659 time-val (YYYYMMDDHHMMSS[.sss]), which is always UTC
+func parseTimeVal(value string) (time.Time, error) {
+	if len(value) < 14 {
+		return time.Time{}, errors.New("Invalid time-val: " + value)
+	}
+
+	layout := "20060102150405"
+	if len(value) > 15 && value[14] == '.' {
+		layout += "." + strings.Repeat("0", len(value)-15)
+	}
+	return time.ParseInLocation(layout, value, time.UTC)
+}
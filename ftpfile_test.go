@@ -0,0 +1,99 @@
+package ftpgo
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseMlsxFormat(t *testing.T) {
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		input  string
+		name   string
+		mode   os.FileMode
+		size   int64
+		mtime  time.Time
+		perm   string
+		unique string
+		owner  string
+		target string
+	}{
+		{
+			input: "type=file;size=1024;modify=20200102030405;perm=adfrw;unique=801U1; a file.txt",
+			name:  "a file.txt", size: 1024, mtime: mtime, perm: "adfrw", unique: "801U1",
+		},
+		{input: "Type=Dir;Modify=20200102030405; docs", name: "docs", mode: os.ModeDir, mtime: mtime},
+		{input: "type=cdir; /pub", name: "/pub", mode: os.ModeDir},
+		{input: "type=pdir; ..", name: "..", mode: os.ModeDir},
+		{
+			input: "type=file;unix.mode=0644;unix.owner=ftp; a.txt",
+			name:  "a.txt", mode: 0644, owner: "ftp",
+		},
+		{input: "type=dir;unix.mode=41755; tmp", name: "tmp", mode: os.ModeDir | 0755},
+		{input: "type=OS.unix=symlink; link", name: "link", mode: os.ModeSymlink},
+		{input: "type=OS.unix=slink:/srv/target; link", name: "link", mode: os.ModeSymlink, target: "/srv/target"},
+		// only the first space ends the facts
+		{input: "size=3;  two spaces", name: " two spaces", size: 3},
+	}
+	for _, tt := range tests {
+		f, err := ParseMlsxFormat(tt.input)
+		if err != nil {
+			t.Errorf("ParseMlsxFormat(%q): %v", tt.input, err)
+			continue
+		}
+		if f.Name() != tt.name || f.Mode() != tt.mode || f.Size() != tt.size || !f.ModTime().Equal(tt.mtime) ||
+			f.Perm() != tt.perm || f.Unique() != tt.unique || f.Owner() != tt.owner || f.Target() != tt.target {
+			t.Errorf("ParseMlsxFormat(%q) = name %q, mode %v, size %d, mtime %v, perm %q, unique %q, owner %q, target %q",
+				tt.input, f.Name(), f.Mode(), f.Size(), f.ModTime(), f.Perm(), f.Unique(), f.Owner(), f.Target())
+		}
+	}
+
+	f, err := ParseMlsxFormat("Type=file;X.Custom=yes; a.txt")
+	if err != nil || f.Fact("type") != "file" || f.Fact("X.CUSTOM") != "yes" || f.Fact("size") != "" {
+		t.Errorf("facts of %v: %v", f, err)
+	}
+
+	for _, input := range []string{
+		"",
+		"a.txt",
+		"type=file;size=12;modify=20200102030405;",
+		"nofacts a.txt",
+		"type=file;bad; a.txt",
+		"size=big; a.txt",
+		"modify=2020; a.txt",
+		"unix.mode=0999; a.txt",
+	} {
+		if f, err := ParseMlsxFormat(input); err == nil {
+			t.Errorf("ParseMlsxFormat(%q) = %v", input, f)
+		}
+	}
+}
+
+func TestParseTimeVal(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Time
+		ok    bool
+	}{
+		{"20200102030405", time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), true},
+		{"20200102030405.5", time.Date(2020, 1, 2, 3, 4, 5, 500e6, time.UTC), true},
+		{"20200102030405.123", time.Date(2020, 1, 2, 3, 4, 5, 123e6, time.UTC), true},
+		{"19991231235959.000001", time.Date(1999, 12, 31, 23, 59, 59, 1e3, time.UTC), true},
+		{"2020010203040", time.Time{}, false},
+		{"20200102030405.", time.Time{}, false},
+		{"20201302030405", time.Time{}, false},
+		{"2020010203040x", time.Time{}, false},
+		{"", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, err := parseTimeVal(tt.value)
+		if (err == nil) != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("parseTimeVal(%q) = %v, %v", tt.value, got, err)
+			continue
+		}
+		if tt.ok && got.Location() != time.UTC {
+			t.Errorf("parseTimeVal(%q) is in %v", tt.value, got.Location())
+		}
+	}
+}
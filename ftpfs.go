@@ -0,0 +1,262 @@
+package ftpgo
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+)
+
+// FtpFS is a fs.FS over the tree below a directory of a FTP session.
+// It also implements fs.ReadDirFS, fs.StatFS and fs.ReadFileFS.
+//
+// A session carries one transfer at a time: while a file opened with Open is
+// being read no other method of the FtpFS or the Ftp may be called, so close it first.
+type FtpFS struct {
+	c    *Ftp
+	root string
+}
+
+// NewFtpFS returns a file system for the remote directory root.
+// An empty root is the current directory of the session.
+func NewFtpFS(c *Ftp, root string) *FtpFS {
+	return &FtpFS{c: c, root: root}
+}
+
+// Open opens the named file for reading, streaming its contents with RETR.
+// A directory is opened as a fs.ReadDirFile.
+func (fsys *FtpFS) Open(name string) (fs.File, error) {
+	info, err := fsys.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		return &ftpDir{fsys: fsys, name: name, info: info}, nil
+	}
+
+	r, err := fsys.c.RetrRequest(fsys.remotePath(name))
+	if err != nil {
+		return nil, fsError("open", name, err)
+	}
+	return &ftpFile{info: info, r: r}, nil
+}
+
+// Stat returns the FtpFile describing the named file.
+// MLST is used when the server supports it, otherwise the parent directory is listed.
+func (fsys *FtpFS) Stat(name string) (fs.FileInfo, error) {
+	info, err := fsys.stat("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// ReadDir reads the named directory and returns its entries sorted by filename.
+func (fsys *FtpFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	infos, err := fsys.readDir(name)
+	if err != nil {
+		return nil, fsError("readdir", name, err)
+	}
+
+	entries := make([]fs.DirEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = info
+	}
+	return entries, nil
+}
+
+// ReadFile reads the named file and returns its contents.
+func (fsys *FtpFS) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrInvalid}
+	}
+
+	r, err := fsys.c.RetrRequest(fsys.remotePath(name))
+	if err != nil {
+		return nil, fsError("readfile", name, err)
+	}
+
+	data, err := io.ReadAll(r)
+	if cerr := r.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, fsError("readfile", name, err)
+	}
+	return data, nil
+}
+
+// remotePath converts a fs.FS path to the path on the server.
+func (fsys *FtpFS) remotePath(name string) string {
+	if fsys.root == "" {
+		return name
+	}
+	return path.Join(fsys.root, name)
+}
+
+// stat
+func (fsys *FtpFS) stat(op, name string) (*FtpFile, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	if fsys.c.hasFeature("MLST") {
+		info, err := fsys.c.Mlst(fsys.remotePath(name))
+		if err != nil {
+			return nil, fsError(op, name, err)
+		}
+		info.name = path.Base(name)
+		return info, nil
+	}
+
+	if name == "." {
+		// without MLST there is no way to ask about the directory itself
+		return &FtpFile{name: ".", mode: fs.ModeDir | 0755}, nil
+	}
+
+	dir, elem := path.Split(name)
+	infos, err := fsys.readDir(path.Clean(dir))
+	if err != nil {
+		return nil, fsError(op, name, err)
+	}
+	for _, info := range infos {
+		if info.name == elem {
+			return info, nil
+		}
+	}
+	return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+}
+
+// readDir lists the directory sorted by name, without the "." and ".." entries.
+func (fsys *FtpFS) readDir(name string) ([]*FtpFile, error) {
+	infos, err := fsys.c.Dir(fsys.remotePath(name))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := infos[:0]
+	for _, info := range infos {
+		if info.name != "." && info.name != ".." {
+			// some servers answer with paths instead of names
+			info.name = path.Base(info.name)
+			entries = append(entries, info)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].name < entries[j].name
+	})
+	return entries, nil
+}
+
+// fsError converts a server reply to the corresponding fs error.
+func fsError(op, name string, err error) error {
+	return &fs.PathError{Op: op, Path: name, Err: fsErr(err)}
+}
+
+// fsErr maps a server reply to fs.ErrNotExist, fs.ErrExist or fs.ErrPermission.
+func fsErr(err error) error {
+	switch {
+	case errors.Is(err, fs.ErrPermission), errors.Is(err, ErrAuthFailed):
+		return fs.ErrPermission
+	case errors.Is(err, fs.ErrExist):
+		return fs.ErrExist
+	case errors.Is(err, fs.ErrNotExist):
+		return fs.ErrNotExist
+	}
+	return err
+}
+
+// ftpFile is a regular file opened by FtpFS.Open.
+type ftpFile struct {
+	info *FtpFile
+	r    io.ReadCloser
+}
+
+func (f *ftpFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *ftpFile) Read(buf []byte) (int, error) {
+	return f.r.Read(buf)
+}
+
+func (f *ftpFile) Close() error {
+	return f.r.Close()
+}
+
+func (f *ftpFile) Write([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "write", Path: f.info.name, Err: fs.ErrPermission}
+}
+
+func (f *ftpFile) Name() string {
+	return f.info.name
+}
+
+// ftpDir is a directory opened by FtpFS.Open. The entries are listed on the first ReadDir.
+type ftpDir struct {
+	fsys    *FtpFS
+	name    string
+	info    *FtpFile
+	entries []fs.DirEntry
+	listed  bool
+}
+
+func (d *ftpDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *ftpDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: fs.ErrInvalid}
+}
+
+func (d *ftpDir) Close() error {
+	return nil
+}
+
+func (d *ftpDir) Write([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "write", Path: d.name, Err: fs.ErrInvalid}
+}
+
+func (d *ftpDir) Name() string {
+	return d.name
+}
+
+func (d *ftpDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.listed {
+		entries, err := d.fsys.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries, d.listed = entries, true
+	}
+
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+// compile time checks
+var (
+	_ fs.ReadDirFS   = (*FtpFS)(nil)
+	_ fs.StatFS      = (*FtpFS)(nil)
+	_ fs.ReadFileFS  = (*FtpFS)(nil)
+	_ fs.ReadDirFile = (*ftpDir)(nil)
+	_ fs.DirEntry    = (*FtpFile)(nil)
+)
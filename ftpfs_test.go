@@ -0,0 +1,76 @@
+package ftpgo
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// addTree serves the files below root, keyed by their path relative to root,
+// with a LIST output for every directory.
+func (s *testServer) addTree(root string, files map[string]string) {
+	entries := map[string]map[string]string{".": {}}
+	add := func(dir, name, line string) {
+		if entries[dir] == nil {
+			entries[dir] = map[string]string{}
+		}
+		entries[dir][name] = line
+	}
+	for name, data := range files {
+		s.files[path.Join(root, name)] = []byte(data)
+		add(path.Dir(name), path.Base(name), fmt.Sprintf("-rw-r--r--  1 ftp ftp %8d Jan 02  2020 %s", len(data), path.Base(name)))
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			add(path.Dir(dir), path.Base(dir), fmt.Sprintf("drwxr-xr-x  2 ftp ftp     4096 Jan 02  2020 %s", path.Base(dir)))
+		}
+	}
+	for dir, lines := range entries {
+		var list []string
+		for _, line := range lines {
+			list = append(list, line+"\r\n")
+		}
+		sort.Strings(list)
+		s.lists[path.Join(root, dir)] = strings.Join(list, "")
+	}
+}
+
+func TestFtpFS(t *testing.T) {
+	s := newTestServer(t)
+	s.addTree("/pub", map[string]string{
+		"a.txt":           "hello",
+		"empty":           "",
+		"dir/b.txt":       "in a directory",
+		"dir/sub/c.bin":   "\x00\x01\x02",
+		"other/d.txt":     "other directory",
+		"other/e.txt":     strings.Repeat("e", 100000),
+		"other/deep/f.md": "# f",
+	})
+	c := s.dial()
+	fsys := NewFtpFS(c, "/pub")
+
+	if err := fstest.TestFS(fsys, "a.txt", "empty", "dir/b.txt", "dir/sub/c.bin", "other/e.txt", "other/deep/f.md"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fs.Stat(fsys, "missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Stat(missing) = %v", err)
+	}
+	if _, err := fs.ReadFile(fsys, "dir/missing.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("ReadFile(missing) = %v", err)
+	}
+	if _, err := fs.ReadDir(fsys, "nodir"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("ReadDir(missing) = %v", err)
+	}
+	if _, err := fsys.Open("../etc/passwd"); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatalf("Open(invalid) = %v", err)
+	}
+
+	// the session is usable after the walk
+	if size, err := c.Size("/pub/a.txt"); err != nil || size != 5 {
+		t.Fatalf("Size = %d, %v", size, err)
+	}
+}
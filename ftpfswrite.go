@@ -0,0 +1,252 @@
+package ftpgo
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// File is a file opened by FtpFS.OpenFile. Files opened for reading return an error
+// from Write, files opened for writing return an error from Read.
+type File interface {
+	fs.File
+	io.Writer
+	Name() string
+}
+
+// OpenFile opens the named file with the os.O_* flags. Reading streams the file with RETR,
+// writing stores it with STOR, or with APPE when os.O_APPEND is given. A file opened for
+// writing is always rewritten from the start, and os.O_RDWR is not supported.
+// perm is not applied; use Chmod.
+func (fsys *FtpFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	switch flag & (os.O_RDONLY | os.O_WRONLY | os.O_RDWR) {
+	case os.O_RDONLY:
+		f, err := fsys.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		return f.(File), nil
+	case os.O_RDWR:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if flag&os.O_CREATE == 0 || flag&os.O_EXCL != 0 {
+		_, err := fsys.stat("open", name)
+		switch {
+		case err == nil && flag&os.O_CREATE != 0:
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+		case err != nil && (flag&os.O_CREATE == 0 || !errors.Is(err, fs.ErrNotExist)):
+			return nil, err
+		}
+	}
+
+	var w io.WriteCloser
+	var err error
+	if flag&os.O_APPEND != 0 {
+		w, err = fsys.c.AppeRequest(fsys.remotePath(name))
+	} else {
+		w, err = fsys.c.StorRequest(fsys.remotePath(name))
+	}
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fsys.writeErr("open", path.Dir(name), err)}
+	}
+	return &ftpWriter{name: name, w: w}, nil
+}
+
+// Create creates or truncates the named file and opens it for writing.
+func (fsys *FtpFS) Create(name string) (File, error) {
+	return fsys.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// Mkdir creates the named directory with MKD. perm is not applied; use Chmod.
+func (fsys *FtpFS) Mkdir(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if _, err := fsys.c.Mkd(fsys.remotePath(name)); err != nil {
+		// most servers answer 550 for an existing directory as well
+		if info, serr := fsys.stat("mkdir", name); serr == nil && info.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+		}
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fsys.writeErr("mkdir", path.Dir(name), err)}
+	}
+	return nil
+}
+
+// MkdirAll creates the named directory along with any missing parents, one MKD per element.
+// It does nothing when the directory already exists.
+func (fsys *FtpFS) MkdirAll(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return nil
+	}
+
+	var dir string
+	for _, elem := range strings.Split(name, "/") {
+		dir = path.Join(dir, elem)
+		err := fsys.Mkdir(dir, perm)
+		if err != nil && !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+	}
+
+	info, err := fsys.stat("mkdir", name)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	return nil
+}
+
+// Remove removes the named file with DELE, or the named empty directory with RMD.
+func (fsys *FtpFS) Remove(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+
+	err := fsys.c.Delete(fsys.remotePath(name))
+	if err == nil {
+		return nil
+	}
+	if rerr := fsys.c.Rmd(fsys.remotePath(name)); rerr == nil {
+		return nil
+	}
+	return &fs.PathError{Op: "remove", Path: name, Err: fsys.writeErr("remove", name, err)}
+}
+
+// RemoveAll removes the named file or directory with everything it contains.
+// It returns nil when the path does not exist.
+func (fsys *FtpFS) RemoveAll(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "removeall", Path: name, Err: fs.ErrInvalid}
+	}
+
+	info, err := fsys.stat("removeall", name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if !info.IsDir() {
+		return fsys.Remove(name)
+	}
+
+	infos, err := fsys.readDir(name)
+	if err != nil {
+		return fsError("removeall", name, err)
+	}
+	for _, info := range infos {
+		child := path.Join(name, info.name)
+		if info.IsDir() {
+			err = fsys.RemoveAll(child)
+		} else {
+			err = fsys.Remove(child)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err = fsys.c.Rmd(fsys.remotePath(name)); err != nil {
+		return &fs.PathError{Op: "removeall", Path: name, Err: fsys.writeErr("removeall", name, err)}
+	}
+	return nil
+}
+
+// Rename renames (moves) oldname to newname with RNFR/RNTO.
+func (fsys *FtpFS) Rename(oldname, newname string) error {
+	if !fs.ValidPath(oldname) || !fs.ValidPath(newname) {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: fs.ErrInvalid}
+	}
+
+	if err := fsys.c.Rename(fsys.remotePath(oldname), fsys.remotePath(newname)); err != nil {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: fsys.writeErr("rename", oldname, err)}
+	}
+	return nil
+}
+
+// Chmod changes the permission bits of the named file with SITE CHMOD.
+func (fsys *FtpFS) Chmod(name string, mode fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "chmod", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if err := fsys.c.Chmod(fsys.remotePath(name), mode); err != nil {
+		return &fs.PathError{Op: "chmod", Path: name, Err: fsys.writeErr("chmod", name, err)}
+	}
+	return nil
+}
+
+// Chtimes changes the modification time of the named file with MFMT.
+// FTP has no access time, so atime is ignored.
+func (fsys *FtpFS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "chtimes", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if err := fsys.c.SetModTime(fsys.remotePath(name), mtime); err != nil {
+		return &fs.PathError{Op: "chtimes", Path: name, Err: fsys.writeErr("chtimes", name, err)}
+	}
+	return nil
+}
+
+// writeErr maps the failure of a command changing the file system like fsErr. A 550 reply
+// which tells nothing more is checked against need, the file or directory the command
+// works on: the error is fs.ErrNotExist when it is missing and fs.ErrPermission otherwise.
+func (fsys *FtpFS) writeErr(op, need string, err error) error {
+	var e *FtpError
+	if errors.As(err, &e) && e.Code == StatusFileUnavailable && !e.isPermission() && !e.isExist() {
+		_, serr := fsys.stat(op, need)
+		switch {
+		case serr == nil:
+			return fs.ErrPermission
+		case errors.Is(serr, fs.ErrNotExist):
+			return fs.ErrNotExist
+		}
+	}
+	return fsErr(err)
+}
+
+// ftpWriter is a file opened for writing by FtpFS.OpenFile.
+type ftpWriter struct {
+	name    string
+	w       io.WriteCloser
+	written int64
+}
+
+func (f *ftpWriter) Stat() (fs.FileInfo, error) {
+	// the session is busy with the transfer, so only what is known locally can be reported
+	return &FtpFile{name: path.Base(f.name), size: f.written, mode: 0644}, nil
+}
+
+func (f *ftpWriter) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrPermission}
+}
+
+func (f *ftpWriter) Write(buf []byte) (int, error) {
+	n, err := f.w.Write(buf)
+	f.written += int64(n)
+	return n, err
+}
+
+func (f *ftpWriter) Close() error {
+	return f.w.Close()
+}
+
+func (f *ftpWriter) Name() string {
+	return f.name
+}
@@ -0,0 +1,194 @@
+package ftpgo
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"testing"
+	"time"
+)
+
+// newWriteFS returns a FtpFS over the directory /pub of a new server.
+func newWriteFS(t *testing.T, feat ...string) (*testServer, *FtpFS) {
+	s := newTestServer(t, feat...)
+	s.dirs["/pub"] = true
+	s.files["/pub/a.txt"] = []byte("hello")
+	return s, NewFtpFS(s.dial(), "/pub")
+}
+
+// file returns the contents of a file of the server and whether it exists.
+func (s *testServer) file(name string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.files[name]
+	return string(data), ok
+}
+
+func TestFtpFSWrite(t *testing.T) {
+	s, fsys := newWriteFS(t)
+
+	write := func(name string, flag int, data string) error {
+		f, err := fsys.OpenFile(name, flag, 0644)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, data); err != nil {
+			f.Close()
+			return err
+		}
+		if info, err := f.Stat(); err != nil || info.Size() != int64(len(data)) {
+			t.Fatalf("Stat while writing = %v, %v", info, err)
+		}
+		return f.Close()
+	}
+
+	if err := write("new.txt", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, "created"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := s.file("/pub/new.txt"); data != "created" {
+		t.Fatalf("created %q", data)
+	}
+	if err := write("new.txt", os.O_WRONLY|os.O_APPEND, " and appended"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := s.file("/pub/new.txt"); data != "created and appended" {
+		t.Fatalf("appended %q", data)
+	}
+
+	if err := write("a.txt", os.O_WRONLY|os.O_CREATE|os.O_EXCL, "x"); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("O_EXCL on an existing file = %v", err)
+	}
+	if err := write("missing.txt", os.O_WRONLY, "x"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("no O_CREATE on a missing file = %v", err)
+	}
+	if _, err := fsys.OpenFile("a.txt", os.O_RDWR, 0); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatalf("O_RDWR = %v", err)
+	}
+	if data, _ := s.file("/pub/a.txt"); data != "hello" {
+		t.Fatalf("a.txt changed to %q", data)
+	}
+
+	f, err := fsys.OpenFile("a.txt", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("read %q, %v", data, err)
+	}
+	if _, err := f.Write([]byte("x")); !errors.Is(err, fs.ErrPermission) {
+		t.Fatalf("Write on a read-only file = %v", err)
+	}
+
+	if err := fsys.Rename("new.txt", "renamed.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.file("/pub/renamed.txt"); !ok {
+		t.Fatal("not renamed")
+	}
+	if err := fsys.Rename("new.txt", "again.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Rename(missing) = %v", err)
+	}
+}
+
+func TestFtpFSDirectories(t *testing.T) {
+	s, fsys := newWriteFS(t)
+
+	if err := fsys.Mkdir("docs", 0755); err != nil {
+		t.Fatal(err)
+	}
+	// the server only answers "550 Create directory operation failed."
+	if err := fsys.Mkdir("docs", 0755); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("Mkdir(existing) = %v", err)
+	}
+	if err := fsys.Mkdir("a.txt", 0755); err == nil {
+		t.Fatal("Mkdir over a file succeeded")
+	}
+	if err := fsys.Mkdir("nodir/docs", 0755); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Mkdir in a missing directory = %v", err)
+	}
+
+	if err := fsys.MkdirAll("docs/x/y", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.MkdirAll("docs/x/y", 0755); err != nil {
+		t.Fatalf("MkdirAll(existing) = %v", err)
+	}
+	if err := fsys.MkdirAll("a.txt/x", 0755); err == nil {
+		t.Fatal("MkdirAll below a file succeeded")
+	}
+	s.mu.Lock()
+	s.files["/pub/docs/x/y/f.txt"] = []byte("f")
+	s.files["/pub/docs/g.txt"] = []byte("g")
+	s.mu.Unlock()
+
+	if err := fsys.RemoveAll("docs"); err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	left := len(s.files) != 1 || len(s.dirs) != 1
+	s.mu.Unlock()
+	if left {
+		t.Fatalf("RemoveAll left %v %v", s.files, s.dirs)
+	}
+	if err := fsys.RemoveAll("docs"); err != nil {
+		t.Fatalf("RemoveAll(missing) = %v", err)
+	}
+
+	if err := fsys.Remove("a.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Remove("a.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Remove(missing) = %v", err)
+	}
+}
+
+func TestFtpFSWriteErrors(t *testing.T) {
+	s, fsys := newWriteFS(t, "MFMT")
+	s.dirs["/pub/ro"] = true
+	s.files["/pub/ro/keep.txt"] = []byte("keep")
+	// replies which do not tell why
+	s.replies["DELE /pub/ro/keep.txt"] = "550 Delete operation failed."
+	s.replies["RMD /pub/ro/keep.txt"] = "550 Remove directory operation failed."
+	s.replies["STOR /pub/ro/new.txt"] = "550 Could not create file."
+	s.replies["STOR /pub/nodir/new.txt"] = "550 Could not create file."
+	s.replies["STOR /pub/bad:name"] = "553 Could not create file."
+	s.replies["SITE"] = "550 SITE CHMOD command failed."
+	s.replies["MFMT"] = "550 Could not set file modification time."
+
+	tests := []struct {
+		op   string
+		err  error
+		want error
+	}{
+		{"remove", fsys.Remove("ro/keep.txt"), fs.ErrPermission},
+		{"create", createErr(fsys, "ro/new.txt"), fs.ErrPermission},
+		{"create in a missing directory", createErr(fsys, "nodir/new.txt"), fs.ErrNotExist},
+		{"create with 553", createErr(fsys, "bad:name"), fs.ErrPermission},
+		{"chmod", fsys.Chmod("ro/keep.txt", 0600), fs.ErrPermission},
+		{"chmod missing", fsys.Chmod("ro/missing.txt", 0600), fs.ErrNotExist},
+		{"chtimes", fsys.Chtimes("ro/keep.txt", time.Now(), time.Now()), fs.ErrPermission},
+		{"invalid", fsys.Remove("../x"), fs.ErrInvalid},
+	}
+	for _, tt := range tests {
+		if !errors.Is(tt.err, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.op, tt.err, tt.want)
+		}
+	}
+	if _, ok := s.file("/pub/ro/keep.txt"); !ok {
+		t.Fatal("keep.txt removed")
+	}
+}
+
+// createErr returns the error of creating and closing the named file.
+func createErr(fsys *FtpFS, name string) error {
+	f, err := fsys.Create(name)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,141 @@
+package ftpgo
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+// FxpError reports which server refused a server-to-server transfer.
+type FxpError struct {
+	// Server is "source" or "destination".
+	Server string
+	// Op is the command which failed.
+	Op  string
+	Err error
+}
+
+// Error describes the failure, with a hint when the server refused the foreign address.
+func (e *FxpError) Error() string {
+	msg := "FXP: " + e.Server + " server rejected " + e.Op + ": " + e.Err.Error()
+	switch {
+	case e.Op == "PORT" || e.Op == "EPRT":
+		msg += " (the server may refuse PORT to an address other than the client's)"
+	case hasCode(e.Err, StatusCanNotOpenDataConnection):
+		msg += " (the data connection between the servers failed; one of them may refuse foreign addresses)"
+	}
+	return msg
+}
+
+// Unwrap returns the reply of the server.
+func (e *FxpError) Unwrap() error {
+	return e.Err
+}
+
+// Fxp copies the file srcPath of src to dstPath on dst directly between the two servers (FXP),
+// without the data going through this host. dst is put in passive mode and src is pointed
+// at its address with PORT, then RETR and STOR run at once until both servers reply 226.
+// Both sessions must be logged in and use the same TYPE, and the data connections must
+// be clear (PROT C). Most servers refuse FXP unless it is enabled in their configuration,
+// which is reported with a *FxpError.
+func Fxp(src *Ftp, srcPath string, dst *Ftp, dstPath string) error {
+	return FxpContext(context.Background(), src, srcPath, dst, dstPath)
+}
+
+// FxpContext is Fxp, aborting the transfer on both servers when ctx is done.
+func FxpContext(ctx context.Context, src *Ftp, srcPath string, dst *Ftp, dstPath string) error {
+	if src.tlsData || dst.tlsData {
+		return errors.New("FXP requires clear data connections (PROT C)")
+	}
+	if err := src.ensureConnected(ctx); err != nil {
+		return err
+	}
+	if err := dst.ensureConnected(ctx); err != nil {
+		return err
+	}
+
+	stopSrc := src.watchContext(ctx, nil)
+	stopDst := dst.watchContext(ctx, nil)
+	err := fxp(src, srcPath, dst, dstPath)
+	firedSrc, firedDst := stopSrc(), stopDst()
+	if firedSrc || firedDst {
+		fxpAbort(src)
+		fxpAbort(dst)
+		return ctx.Err()
+	}
+	return err
+}
+
+// fxp
+func fxp(src *Ftp, srcPath string, dst *Ftp, dstPath string) error {
+	host, port, err := dst.makePasv()
+	if err != nil {
+		return &FxpError{Server: "destination", Op: "PASV", Err: err}
+	}
+	if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+		// the server does not know its address, use the one of the control connection
+		host, _, _ = net.SplitHostPort(dst.conn.RemoteAddr().String())
+	}
+
+	op := "PORT"
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		op = "EPRT"
+		err = src.Eprt(host, port)
+	} else {
+		err = src.Port(host, port)
+	}
+	if err != nil {
+		return &FxpError{Server: "source", Op: op, Err: err}
+	}
+
+	// a passive server may only answer STOR once the source has connected,
+	// so both commands are sent before any reply is read
+	if err := dst.putCmd("STOR %s", dstPath); err != nil {
+		return err
+	}
+	if err := src.putCmd("RETR %s", srcPath); err != nil {
+		fxpAbort(dst)
+		return err
+	}
+
+	if err := fxpExpect(src, StatusAlreadyOpen, StatusAboutToSend); err != nil {
+		fxpAbort(dst)
+		return &FxpError{Server: "source", Op: "RETR", Err: err}
+	}
+	if err := fxpExpect(dst, StatusAlreadyOpen, StatusAboutToSend); err != nil {
+		// the source fails to send, read its final reply
+		fxpExpect(src, StatusClosingDataConnection)
+		return &FxpError{Server: "destination", Op: "STOR", Err: err}
+	}
+
+	errSrc := fxpExpect(src, StatusClosingDataConnection, StatusRequestedFileActionOK)
+	errDst := fxpExpect(dst, StatusClosingDataConnection, StatusRequestedFileActionOK)
+	if errSrc != nil {
+		return &FxpError{Server: "source", Op: "RETR", Err: errSrc}
+	}
+	if errDst != nil {
+		return &FxpError{Server: "destination", Op: "STOR", Err: errDst}
+	}
+	return nil
+}
+
+// fxpExpect reads a reply and fails unless it has one of the codes.
+func fxpExpect(c *Ftp, codes ...int) error {
+	code, msg, err := c.getResponse(-1)
+	if err != nil {
+		return err
+	}
+	for _, expected := range codes {
+		if code == expected {
+			return nil
+		}
+	}
+	return newFtpError(code, msg)
+}
+
+// fxpAbort aborts the pending transfer of one side of a FXP transfer and skips the
+// replies left on its control connection.
+func fxpAbort(c *Ftp) {
+	c.abortTransfer()
+	c.resync()
+}
@@ -0,0 +1,59 @@
+package ftpgo
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFxp(t *testing.T) {
+	srcServer, dstServer := newTestServer(t), newTestServer(t)
+	srcServer.files["/f"] = []byte("server to server")
+	src, dst := srcServer.dial(), dstServer.dial()
+
+	if err := Fxp(src, "/f", dst, "/g"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := dstServer.file("/g"); data != "server to server" {
+		t.Fatalf("copied %q", data)
+	}
+	srcServer.mu.Lock()
+	cmds := strings.Join(srcServer.cmds, "\n")
+	srcServer.mu.Unlock()
+	if !strings.Contains(cmds, "\nPORT 127,0,0,1,") {
+		t.Fatalf("source not pointed at the destination:\n%s", cmds)
+	}
+
+	// both sessions can be used afterwards
+	if err := src.Noop(); err != nil {
+		t.Fatal(err)
+	}
+	if size, err := dst.Size("/g"); err != nil || size != 16 {
+		t.Fatalf("Size = %d, %v", size, err)
+	}
+}
+
+func TestFxpErrors(t *testing.T) {
+	srcServer, dstServer := newTestServer(t), newTestServer(t)
+	srcServer.files["/f"] = []byte("data")
+	srcServer.replies["PORT"] = "500 Illegal PORT command"
+	src, dst := srcServer.dial(), dstServer.dial()
+
+	err := Fxp(src, "/f", dst, "/g")
+	var fxpErr *FxpError
+	if !errors.As(err, &fxpErr) || fxpErr.Server != "source" || fxpErr.Op != "PORT" || !hasCode(err, 500) {
+		t.Fatalf("Fxp = %v", err)
+	}
+	if !strings.Contains(err.Error(), "other than the client's") {
+		t.Fatalf("no hint in %q", err)
+	}
+	if dstServer.sent("STOR /g") {
+		t.Fatal("STOR sent after PORT failed")
+	}
+
+	// the data would have to be protected on both servers
+	dst.tlsData = true
+	if err := Fxp(src, "/f", dst, "/g"); err == nil || !strings.Contains(err.Error(), "PROT C") {
+		t.Fatalf("Fxp with protected data = %v", err)
+	}
+}
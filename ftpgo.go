@@ -1,12 +1,15 @@
 package ftpgo
 
 import (
-	"bufio"
+	"context"
+	"crypto/tls"
 	"errors"
+	"fmt"
 	"io"
 	"net"
 	"net/textproto"
 	"os"
+	pathpkg "path"
 	"regexp"
 	"strconv"
 	"strings"
@@ -16,9 +19,29 @@ import (
 //Ftp struct
 type Ftp struct {
 	passive       bool
+	extended      bool
 	textprotoConn *textproto.Conn
 	conn          net.Conn
 	timeout       time.Duration
+	addr          string
+	tlsConfig     *tls.Config
+	tlsData       bool
+	features      Features
+	progress      ProgressFunc
+	limiter       *RateLimiter
+	implicitTLS   bool
+	explicitTLS   bool
+	user          string
+	password      string
+	transferType  string
+	cwd           string
+	retry         *RetryPolicy
+	broken        bool
+	reconnecting  bool
+	retrying      bool
+	hashAlgo      string
+	charset       Charset
+	utf8          bool
 }
 
 var regexp227 *regexp.Regexp
@@ -29,55 +52,114 @@ func init() {
 
 //FtpConnect Connect to server
 func FtpConnect(addr string, timeout time.Duration) (*Ftp, error) {
-	conn, err := net.DialTimeout("tcp4", addr, timeout)
-	if err != nil {
+	return FtpConnectContext(context.Background(), addr, timeout)
+}
+
+// FtpConnectContext Connect to server, giving up when ctx is done.
+// timeout bounds the dial of the control connection and of every data connection.
+func FtpConnectContext(ctx context.Context, addr string, timeout time.Duration) (*Ftp, error) {
+	c := &Ftp{addr: addr, timeout: timeout}
+	if err := c.connect(ctx); err != nil {
 		return nil, err
 	}
 
-	textprotoConn := textproto.NewConn(conn)
-	c := &Ftp{
-		passive:       false,
-		textprotoConn: textprotoConn,
-		conn:          conn,
-		timeout:       timeout,
+	return c, nil
+}
+
+// connect dials the control connection and reads the greeting.
+// EPSV/EPRT are preferred when the control connection is IPv6.
+func (c *Ftp) connect(ctx context.Context) error {
+	dialer := &net.Dialer{Timeout: c.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return err
 	}
 
+	if tcpaddr, ok := conn.RemoteAddr().(*net.TCPAddr); ok {
+		c.extended = tcpaddr.IP.To4() == nil
+	}
+	if c.implicitTLS {
+		if conn, err = c.tlsHandshake(conn); err != nil {
+			return err
+		}
+	}
+	c.conn = conn
+	c.textprotoConn = textproto.NewConn(conn)
+	c.broken = false
+
+	stop := c.watchContext(ctx, nil)
 	_, _, err = c.getResponse(220)
+	if stop() {
+		err = ctx.Err()
+	}
 	if err != nil {
-		c.Quit()
-		return nil, err
+		// no QUIT, the server may never answer it
+		c.textprotoConn.Close()
+		return err
 	}
 
-	return c, nil
+	if c.implicitTLS {
+		// Data channels are private by default in implicit mode, but some servers
+		// still insist on PBSZ/PROT before the first transfer.
+		c.tlsData = true
+		if err = c.Pbsz(0); err == nil {
+			err = c.Prot("P")
+		}
+		// a server which does not implement them keeps the default
+		if err != nil && !isUnsupported(err) {
+			c.Quit()
+			return err
+		}
+	}
+	return nil
 }
 
 // Login as the given user.
 func (c *Ftp) Login(user, password string) error {
-	code, message, err := c.SendCmd(-1, "USER %s", user)
+	return c.LoginContext(context.Background(), user, password)
+}
+
+// LoginContext as the given user, giving up when ctx is done.
+// After a successful login FEAT is issued to learn the capabilities of the server.
+func (c *Ftp) LoginContext(ctx context.Context, user, password string) error {
+	code, message, err := c.SendCmdContext(ctx, -1, "USER %s", user)
 	if err != nil {
 		return err
 	}
 
-	if code == 331 {
-		_, _, err = c.SendCmd(230, "PASS %s", password)
+	switch code {
+	case StatusLoggedIn:
+		// no password required
+	case StatusUserOK:
+		_, _, err = c.SendCmdContext(ctx, StatusLoggedIn, "PASS %s", password)
 		if err != nil {
 			return err
 		}
-		return nil
+	default:
+		return newFtpError(code, message)
 	}
 
-	return errors.New(message)
+	// kept to log in again after a reconnect
+	c.user, c.password = user, password
+	c.negotiate(ctx)
+	return nil
 }
 
 // Type issues a TYPE FTP command
 func (c *Ftp) Type(param string) error {
 	_, _, err := c.SendCmd(200, "TYPE %s", param)
+	if err == nil {
+		c.transferType = param
+	}
 	return err
 }
 
 // Cwd issues a CWD FTP command, which changes the current directory to the specified path.
 func (c *Ftp) Cwd(path string) error {
 	_, _, err := c.SendCmd(250, "CWD %s", path)
+	if err == nil {
+		c.rememberCwd()
+	}
 	return err
 }
 
@@ -85,6 +167,9 @@ func (c *Ftp) Cwd(path string) error {
 // This is similar to a call to ChangeDir with a path set to "..".
 func (c *Ftp) Cdup() error {
 	_, _, err := c.SendCmd(250, "CDUP")
+	if err == nil {
+		c.rememberCwd()
+	}
 	return err
 }
 
@@ -115,7 +200,7 @@ func (c *Ftp) Delete(path string) error {
 		return err
 	}
 	if code != 250 && code != 200 {
-		return &textproto.Error{Code: code, Msg: msg}
+		return newFtpError(code, msg)
 	}
 	return err
 }
@@ -136,6 +221,13 @@ func (c *Ftp) Rmd(path string) error {
 	return err
 }
 
+// Chmod issues a SITE CHMOD FTP command to change the permission bits of the file.
+// ftp server optional command.
+func (c *Ftp) Chmod(path string, mode os.FileMode) error {
+	_, _, err := c.SendCmd(200, "SITE CHMOD %o %s", mode.Perm(), path)
+	return err
+}
+
 // Noop has no effects and is usually used to prevent the remote FTP server to close the otherwise idle connection.
 func (c *Ftp) Noop() error {
 	_, _, err := c.SendCmd(200, "NOOP")
@@ -161,71 +253,165 @@ func (c *Ftp) Abort() error {
 		return err
 	}
 	if code != 225 && code != 226 {
-		return &textproto.Error{Code: code, Msg: msg}
+		return newFtpError(code, msg)
 	}
 	return err
 }
 
 // Quit issues a QUIT FTP command to properly close the connection from the remote FTP server.
 func (c *Ftp) Quit() error {
-	c.SendCmd(-1, "QUIT")
+	c.sendCmd(-1, "QUIT")
 	return c.textprotoConn.Close()
 }
 
 // Size Request the size of the file named filename on the server.
 // On success, the size of the file is returned as an integer.
 // ftp server extension command.
-func (c *Ftp) Size(filename string) (int, error) {
-	_, msg, err := c.SendCmd(213, "SIZE %s", filename)
+func (c *Ftp) Size(filename string) (size int, err error) {
+	err = c.retryContext(context.Background(), func() (err error) {
+		size, err = c.size(context.Background(), filename)
+		return
+	})
+	return
+}
+
+// size issues SIZE without retrying, for the operations which are retried as a whole.
+func (c *Ftp) size(ctx context.Context, filename string) (int, error) {
+	_, msg, err := c.SendCmdContext(ctx, 213, "SIZE %s", filename)
 	if err != nil {
 		return 0, err
 	}
-
 	return strconv.Atoi(strings.TrimSpace(msg))
 }
 
-// NlstRequest issues an NLST FTP command.
-func (c *Ftp) NlstRequest(args ...string) (io.ReadCloser, error) {
-	cmd := append([]string{"NLST"}, args...)
-	val := strings.Join(cmd, " ")
-	conn, err := c.transferCmd(val)
+// Mdtm issues a MDTM FTP command, which returns the modification time of the file in UTC.
+// ftp server extension command.
+func (c *Ftp) Mdtm(path string) (mtime time.Time, err error) {
+	err = c.retryContext(context.Background(), func() (err error) {
+		mtime, err = c.mdtm(context.Background(), path)
+		return
+	})
+	return
+}
+
+// mdtm issues MDTM without retrying.
+func (c *Ftp) mdtm(ctx context.Context, path string) (time.Time, error) {
+	_, msg, err := c.SendCmdContext(ctx, 213, "MDTM %s", path)
 	if err != nil {
-		return nil, err
+		return time.Time{}, err
 	}
+	return parseTimeVal(strings.TrimSpace(msg))
+}
 
-	return &FtpDataConnector{conn, c}, nil
+// Mfmt issues a MFMT FTP command to set the modification time of the file.
+// ftp server extension command.
+func (c *Ftp) Mfmt(path string, mtime time.Time) error {
+	_, _, err := c.SendCmd(213, "MFMT %s %s", mtime.UTC().Format("20060102150405"), path)
+	return err
 }
 
-// ListRequest issues a LIST FTP command.
-func (c *Ftp) ListRequest(args ...string) (io.ReadCloser, error) {
-	cmd := append([]string{"LIST"}, args...)
-	val := strings.Join(cmd, " ")
-	conn, err := c.transferCmd(val)
+// SetModTime sets the modification time of the remote file, with MFMT when the server
+// advertises it and otherwise with the common "MDTM YYYYMMDDHHMMSS path" variant.
+func (c *Ftp) SetModTime(path string, mtime time.Time) error {
+	if c.hasFeature("MFMT") {
+		return c.Mfmt(path, mtime)
+	}
+
+	code, msg, err := c.SendCmd(-1, "MDTM %s %s", mtime.UTC().Format("20060102150405"), path)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if code != 213 && code != 253 {
+		return newFtpError(code, msg)
 	}
+	return nil
+}
+
+// NlstRequest issues an NLST FTP command.
+func (c *Ftp) NlstRequest(args ...string) (io.ReadCloser, error) {
+	return c.NlstRequestContext(context.Background(), args...)
+}
+
+// NlstRequestContext issues an NLST FTP command. The transfer is aborted when ctx is done.
+func (c *Ftp) NlstRequestContext(ctx context.Context, args ...string) (io.ReadCloser, error) {
+	cmd := append([]string{"NLST"}, args...)
+	return c.transferRequest(ctx, nil, "", "%s", strings.Join(cmd, " "))
+}
 
-	return &FtpDataConnector{conn, c}, nil
+// ListRequest issues a LIST FTP command.
+func (c *Ftp) ListRequest(args ...string) (io.ReadCloser, error) {
+	return c.ListRequestContext(context.Background(), args...)
+}
+
+// ListRequestContext issues a LIST FTP command. The transfer is aborted when ctx is done.
+func (c *Ftp) ListRequestContext(ctx context.Context, args ...string) (io.ReadCloser, error) {
+	cmd := append([]string{"LIST"}, args...)
+	return c.transferRequest(ctx, nil, "", "%s", strings.Join(cmd, " "))
 }
 
 // RetrRequest issues a RETR FTP command to fetch the specified file from the remote FTP server
 // The returned ReadCloser must be closed to cleanup the FTP data connection.
-func (c *Ftp) RetrRequest(path string) (io.ReadCloser, error) {
-	conn, err := c.transferCmd("RETR %s", path)
+func (c *Ftp) RetrRequest(path string, opts ...TransferOption) (io.ReadCloser, error) {
+	return c.RetrRequestContext(context.Background(), path, opts...)
+}
+
+// RetrRequestContext issues a RETR FTP command to fetch the specified file from the remote FTP server.
+// When ctx is done the transfer is aborted with ABOR and Read/Close return ctx.Err().
+func (c *Ftp) RetrRequestContext(ctx context.Context, path string, opts ...TransferOption) (io.ReadCloser, error) {
+	return c.RetrRequestAtContext(ctx, path, 0, opts...)
+}
+
+// RetrRequestAt issues REST and RETR FTP commands to fetch the specified file starting at offset.
+// The returned ReadCloser must be closed to cleanup the FTP data connection.
+func (c *Ftp) RetrRequestAt(path string, offset uint64, opts ...TransferOption) (io.ReadCloser, error) {
+	return c.RetrRequestAtContext(context.Background(), path, offset, opts...)
+}
+
+// RetrRequestAtContext issues REST and RETR FTP commands to fetch the specified file starting at offset.
+// When ctx is done the transfer is aborted with ABOR and Read/Close return ctx.Err().
+func (c *Ftp) RetrRequestAtContext(ctx context.Context, path string, offset uint64, opts ...TransferOption) (io.ReadCloser, error) {
+	r, err := c.retrRequestAt(ctx, path, offset, opts)
 	if err != nil {
 		return nil, err
 	}
-	return &FtpDataConnector{conn, c}, nil
+	return r, nil
+}
+
+// retrRequestAt is RetrRequestAtContext returning the data connection itself, for the
+// callers which abort the transfer.
+func (c *Ftp) retrRequestAt(ctx context.Context, path string, offset uint64, opts []TransferOption) (*FtpDataConnector, error) {
+	t := c.newTransfer(opts)
+	t.offset, t.start = offset, int64(offset)
+	if t.progress != nil && t.total < 0 {
+		if size, err := c.size(ctx, path); err == nil {
+			t.total = int64(size)
+		}
+	}
+	return c.transferRequest(ctx, t, path, "RETR %s", path)
 }
 
 // StorRequest issues a STOR FTP command to store a file to the remote FTP server.
 // The returned WriteCloser must be closed to cleanup the FTP data connection.
-func (c *Ftp) StorRequest(path string) (io.WriteCloser, error) {
-	conn, err := c.transferCmd("STOR %s", path)
-	if err != nil {
-		return nil, err
-	}
-	return &FtpDataConnector{conn, c}, nil
+func (c *Ftp) StorRequest(path string, opts ...TransferOption) (io.WriteCloser, error) {
+	return c.StorRequestContext(context.Background(), path, opts...)
+}
+
+// StorRequestContext issues a STOR FTP command to store a file to the remote FTP server.
+// When ctx is done the transfer is aborted with ABOR and Write/Close return ctx.Err().
+func (c *Ftp) StorRequestContext(ctx context.Context, path string, opts ...TransferOption) (io.WriteCloser, error) {
+	return c.transferRequest(ctx, c.newTransfer(opts), path, "STOR %s", path)
+}
+
+// AppeRequest issues an APPE FTP command to append to a file on the remote FTP server.
+// The returned WriteCloser must be closed to cleanup the FTP data connection.
+func (c *Ftp) AppeRequest(path string, opts ...TransferOption) (io.WriteCloser, error) {
+	return c.AppeRequestContext(context.Background(), path, opts...)
+}
+
+// AppeRequestContext issues an APPE FTP command to append to a file on the remote FTP server.
+// When ctx is done the transfer is aborted with ABOR and Write/Close return ctx.Err().
+func (c *Ftp) AppeRequestContext(ctx context.Context, path string, opts ...TransferOption) (io.WriteCloser, error) {
+	return c.transferRequest(ctx, c.newTransfer(opts), path, "APPE %s", path)
 }
 
 // SetPasv sets the mode to passive or active for data transfers.
@@ -233,71 +419,156 @@ func (c *Ftp) SetPasv(ispassive bool) {
 	c.passive = ispassive
 }
 
+// SetEpsv sets whether EPSV/EPRT are used instead of PASV/PORT for data transfers.
+// It is enabled automatically when the control connection is IPv6.
+func (c *Ftp) SetEpsv(isextended bool) {
+	c.extended = isextended
+}
+
 // Nlst issues an NLST FTP command.
 func (c *Ftp) Nlst(args ...string) (lines []string, err error) {
-	cmd := append([]string{"NLST"}, args...)
-	val := strings.Join(cmd, " ")
-	conn, err := c.transferCmd(val)
-	if err != nil {
-		return
-	}
+	return c.NlstContext(context.Background(), args...)
+}
 
-	r := &FtpDataConnector{conn, c}
-	defer r.Close()
+// NlstContext issues an NLST FTP command, giving up when ctx is done.
+// See NlstIter to read the names as they arrive.
+func (c *Ftp) NlstContext(ctx context.Context, args ...string) (lines []string, err error) {
+	err = c.retryContext(ctx, func() error {
+		it, err := c.nlstIter(ctx, args...)
+		if err != nil {
+			return err
+		}
 
-	lines, err = c.getLines(r)
-	if err != nil {
-		return
-	}
+		lines = nil
+		for it.Next() {
+			lines = append(lines, it.Text())
+		}
+		return it.Close()
+	})
 	return
 }
 
 // List issues a LIST FTP command.
 func (c *Ftp) List(args ...string) (lines []string, err error) {
-	cmd := append([]string{"LIST"}, args...)
-	val := strings.Join(cmd, " ")
-	conn, err := c.transferCmd(val)
-	if err != nil {
-		return
-	}
+	return c.ListContext(context.Background(), args...)
+}
 
-	r := &FtpDataConnector{conn, c}
-	defer r.Close()
+// ListContext issues a LIST FTP command, giving up when ctx is done.
+// See ListIter to read the lines as they arrive.
+func (c *Ftp) ListContext(ctx context.Context, args ...string) (lines []string, err error) {
+	err = c.retryContext(ctx, func() error {
+		it, err := c.listIter(ctx, args...)
+		if err != nil {
+			return err
+		}
 
-	lines, err = c.getLines(r)
-	if err != nil {
-		return
-	}
+		lines = nil
+		for it.Next() {
+			lines = append(lines, it.Text())
+		}
+		return it.Close()
+	})
 	return
 }
 
 // Dir issues a LIST FTP command.
 func (c *Ftp) Dir(args ...string) (infos []*FtpFile, err error) {
-	cmd := append([]string{"LIST"}, args...)
-	val := strings.Join(cmd, " ")
-	conn, err := c.transferCmd(val)
-	if err != nil {
-		return
+	return c.DirContext(context.Background(), args...)
+}
+
+// DirContext issues a LIST FTP command, giving up when ctx is done.
+// MLSD is used instead when the server advertises MLST and no LIST options are given;
+// LIST is still used when MLSD refuses the path, e.g. because it is a file.
+// See DirIter to read the entries as they arrive.
+func (c *Ftp) DirContext(ctx context.Context, args ...string) (infos []*FtpFile, err error) {
+	if c.useMlsd(args) {
+		infos, err = c.MlsdContext(ctx, strings.Join(args, ""))
+		if !mlsdRefused(err) {
+			return infos, err
+		}
 	}
+	return c.collectIter(ctx, c.dirIter, args...)
+}
 
-	r := &FtpDataConnector{conn, c}
-	defer r.Close()
+// Mlsd issues a MLSD FTP command (RFC 3659), which lists the directory in a machine-readable format.
+// The entries for the directory itself and its parent are not returned.
+func (c *Ftp) Mlsd(path string) ([]*FtpFile, error) {
+	return c.MlsdContext(context.Background(), path)
+}
 
-	scanner := bufio.NewScanner(r)
-	for scanner.Scan() {
-		line := scanner.Text()
-		fileinfo, err := NewFtpFile(line)
-		if err == nil {
-			infos = append(infos, fileinfo)
+// MlsdContext issues a MLSD FTP command (RFC 3659), giving up when ctx is done.
+// See MlsdIter to read the entries as they arrive.
+func (c *Ftp) MlsdContext(ctx context.Context, path string) (infos []*FtpFile, err error) {
+	return c.collectIter(ctx, c.mlsdIter, path)
+}
+
+// useMlsd tells if a listing with the LIST args can use MLSD instead.
+func (c *Ftp) useMlsd(args []string) bool {
+	return len(args) <= 1 && (len(args) == 0 || !strings.HasPrefix(args[0], "-")) && c.hasFeature("MLST")
+}
+
+// mlsdRefused tells if MLSD failed on its argument, which LIST may still accept:
+// RFC 3659 makes MLSD of a file an error.
+func mlsdRefused(err error) bool {
+	return hasCode(err, StatusBadArguments, StatusFileUnavailable)
+}
+
+// collectIter reads a whole listing into a slice, starting over when the session retries.
+func (c *Ftp) collectIter(ctx context.Context,
+	open func(ctx context.Context, args ...string) (*DirIterator, error), args ...string) (infos []*FtpFile, err error) {
+	err = c.retryContext(ctx, func() error {
+		it, err := open(ctx, args...)
+		if err != nil {
+			return err
 		}
-	}
-	if err = scanner.Err(); err != nil {
+
+		infos = nil
+		for it.Next() {
+			infos = append(infos, it.Entry())
+		}
+		return it.Close()
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return
 }
 
+// Mlst issues a MLST FTP command (RFC 3659) to get the facts of a single file over the control connection.
+// The name of the returned FtpFile is the last element of the pathname.
+func (c *Ftp) Mlst(path string) (*FtpFile, error) {
+	cmd := "MLST"
+	if path != "" {
+		cmd += " " + path
+	}
+	var msg string
+	err := c.retryContext(context.Background(), func() (err error) {
+		_, msg, err = c.SendCmd(250, "%s", cmd)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 250-Listing path
+	//  type=file;size=1024;modify=20170101000000; /path
+	// 250 End
+	for _, line := range strings.Split(msg, "\n") {
+		if !strings.HasPrefix(line, " ") {
+			continue
+		}
+		fileinfo, err := ParseMlsxFormat(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		fileinfo.name = pathpkg.Base(fileinfo.name)
+		return fileinfo, nil
+	}
+
+	return nil, errors.New("No facts in MLST response: " + msg)
+}
+
 // Retr issues a RETR FTP command to fetch the specified file from the remote FTP server
 func (c *Ftp) Retr(path string) error {
 	code, msg, err := c.SendCmd(-1, "RETR %s", path)
@@ -305,7 +576,7 @@ func (c *Ftp) Retr(path string) error {
 		return err
 	}
 	if code != 125 && code != 150 {
-		return &textproto.Error{Code: code, Msg: msg}
+		return newFtpError(code, msg)
 	}
 	return err
 }
@@ -317,7 +588,7 @@ func (c *Ftp) Stor(path string) error {
 		return err
 	}
 	if code != 125 && code != 150 {
-		return &textproto.Error{Code: code, Msg: msg}
+		return newFtpError(code, msg)
 	}
 	return err
 }
@@ -332,71 +603,167 @@ func (c *Ftp) Pasv() (host string, port int, err error) {
 	return parse227(line)
 }
 
+// Epsv issues an EPSV FTP command (RFC 2428) to get a port number for a data connection.
+// The data connection is made to the host of the control connection.
+func (c *Ftp) Epsv() (port int, err error) {
+	_, line, err := c.SendCmd(229, "EPSV")
+	if err != nil {
+		return
+	}
+	// EPSV response format : 229 Entering Extended Passive Mode (|||port|).
+	return parse229(line)
+}
+
 // Port issues a PORT FTP command
 func (c *Ftp) Port(host string, port int) error {
-	hostbytes := strings.Split(host, ".")
+	ip := net.ParseIP(host)
+	if ip == nil || ip.To4() == nil {
+		return errors.New("PORT requires an IPv4 address: " + host)
+	}
+
+	hostbytes := strings.Split(ip.To4().String(), ".")
 	portbytes := []string{strconv.Itoa(port / 256), strconv.Itoa(port % 256)}
 	param := strings.Join(append(hostbytes, portbytes...), ",")
 	_, _, err := c.SendCmd(200, "PORT %s", param)
 	return err
 }
 
+// Eprt issues an EPRT FTP command (RFC 2428), which works with both IPv4 and IPv6 addresses.
+func (c *Ftp) Eprt(host string, port int) error {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return errors.New("Invalid address for EPRT: " + host)
+	}
+
+	proto := 2
+	if ip.To4() != nil {
+		proto = 1
+	}
+	_, _, err := c.SendCmd(200, "EPRT |%d|%s|%d|", proto, ip.String(), port)
+	return err
+}
+
 // RetrFile issues a RETR FTP command to fetch the specified file from the remote FTP server
-func (c *Ftp) RetrFile(remote, local string) error {
-	reader, err := c.RetrRequest(remote)
+func (c *Ftp) RetrFile(remote, local string, opts ...TransferOption) error {
+	return c.RetrFileContext(context.Background(), remote, local, opts...)
+}
+
+// RetrFileContext issues a RETR FTP command to fetch the specified file from the remote FTP server.
+// The transfer is aborted when ctx is done.
+// With a retry policy an interrupted download resumes where it stopped,
+// or starts over in ASCII mode.
+func (c *Ftp) RetrFileContext(ctx context.Context, remote, local string, opts ...TransferOption) error {
+	retried := false
+	return c.retryContext(ctx, func() error {
+		if retried && !c.asciiTransfer(c.newTransfer(opts), remote) {
+			return c.retrFileResume(ctx, remote, local, opts)
+		}
+		retried = true
+		return c.retrFile(ctx, remote, local, opts)
+	})
+}
+
+// retrFile
+func (c *Ftp) retrFile(ctx context.Context, remote, local string, opts []TransferOption) error {
+	t := c.newTransfer(opts)
+	h, err := c.newTransferHash(t, remote)
 	if err != nil {
 		return err
 	}
-	defer reader.Close()
 
-	file, err := os.Create(local)
+	reader, err := c.RetrRequestContext(ctx, remote, opts...)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	buf := make([]byte, 32*1024)
-	for {
-		nr, err := reader.Read(buf)
-		if nr > 0 {
-			nw, err := file.Write(buf[:nr])
-			if err != nil {
-				return err
-			}
-			if nr != nw {
-				return io.ErrShortWrite
-			}
-		}
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return err
-		}
+	file, err := os.Create(local)
+	if err != nil {
+		reader.Close()
+		return err
 	}
 
-	return nil
+	var dst io.Writer = file
+	if h != nil {
+		dst = io.MultiWriter(file, h)
+	}
+	err = copyData(dst, reader)
+	if cerr := reader.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && h != nil {
+		err = c.verifyHash(t, remote, h.Sum(nil))
+	}
+	return err
 }
 
 // StorFile issues a STOR FTP command to store a file to the remote FTP server.
-func (c *Ftp) StorFile(local, remote string) error {
+func (c *Ftp) StorFile(local, remote string, opts ...TransferOption) error {
+	return c.StorFileContext(context.Background(), local, remote, opts...)
+}
+
+// StorFileContext issues a STOR FTP command to store a file to the remote FTP server.
+// The transfer is aborted when ctx is done.
+func (c *Ftp) StorFileContext(ctx context.Context, local, remote string, opts ...TransferOption) error {
 	file, err := os.Open(local)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
-	writer, err := c.StorRequest(remote)
+	info, err := file.Stat()
 	if err != nil {
 		return err
 	}
-	defer writer.Close()
+	t := c.newTransfer(opts)
+	if t.total < 0 {
+		t.total = info.Size()
+	}
+	h, err := c.newTransferHash(t, remote)
+	if err != nil {
+		return err
+	}
+
+	var src io.Reader = file
+	if h != nil {
+		src = io.TeeReader(file, h)
+	}
+	name := t.tempPath(remote)
+	writer, err := c.transferRequest(ctx, t, name, "STOR %s", name)
+	if err == nil {
+		err = copyData(writer, src)
+		if cerr := writer.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err == nil && h != nil {
+		// checked before the rename, so that a corrupt file is never published
+		err = c.verifyHash(t, name, h.Sum(nil))
+	}
+	if err == nil && name != remote {
+		size := info.Size()
+		if c.asciiTransfer(t, remote) {
+			// the line endings changed the size
+			size = -1
+		}
+		err = c.commitTemp(name, remote, size)
+	}
+	if err != nil && name != remote {
+		// best effort, the connection may be gone
+		c.Delete(name)
+	}
+	return err
+}
 
+// copyData copies src to dst until EOF.
+func copyData(dst io.Writer, src io.Reader) error {
 	buf := make([]byte, 32*1024)
 	for {
-		nr, err := file.Read(buf)
+		nr, err := src.Read(buf)
 		if nr > 0 {
-			nw, err := writer.Write(buf[:nr])
+			nw, err := dst.Write(buf[:nr])
 			if err != nil {
 				return err
 			}
@@ -423,6 +790,27 @@ func (c *Ftp) ReadResponse(expectCode int, t time.Time) (int, string, error) {
 
 // SendCmd Send a simple command string to the server and return the code and response string.
 func (c *Ftp) SendCmd(expectCode int, format string, args ...interface{}) (int, string, error) {
+	return c.SendCmdContext(context.Background(), expectCode, format, args...)
+}
+
+// SendCmdContext Send a simple command string to the server and return the code and response string.
+// When ctx is done the pending I/O is interrupted and ctx.Err() is returned; the reply to the
+// command is then left unread, so the session should be closed with Quit.
+func (c *Ftp) SendCmdContext(ctx context.Context, expectCode int, format string, args ...interface{}) (int, string, error) {
+	if err := c.ensureConnected(ctx); err != nil {
+		return 0, "", err
+	}
+
+	stop := c.watchContext(ctx, nil)
+	code, msg, err := c.sendCmd(expectCode, format, args...)
+	if stop() {
+		return 0, "", ctx.Err()
+	}
+	return code, msg, err
+}
+
+// sendCmd is a helper function to execute a command and read its response.
+func (c *Ftp) sendCmd(expectCode int, format string, args ...interface{}) (int, string, error) {
 	err := c.putCmd(format, args...)
 	if err != nil {
 		return 0, "", err
@@ -433,33 +821,96 @@ func (c *Ftp) SendCmd(expectCode int, format string, args ...interface{}) (int,
 
 // putCmd is a helper function to execute a command.
 func (c *Ftp) putCmd(format string, args ...interface{}) error {
-	_, err := c.textprotoConn.Cmd(format, args...)
+	line, err := c.encode(fmt.Sprintf(format, args...))
+	if err != nil {
+		return err
+	}
+	_, err = c.textprotoConn.Cmd("%s", line)
+	c.checkConn(err)
 	return err
 }
 
 // getResponse is a helper function to check for the expected FTP return code
 func (c *Ftp) getResponse(expectCode int) (int, string, error) {
-	return c.textprotoConn.ReadResponse(expectCode)
+	code, msg, err := c.textprotoConn.ReadResponse(expectCode)
+	msg = c.decode(msg)
+	if e, ok := err.(*textproto.Error); ok {
+		e.Msg = msg
+	}
+	err = replyError(err)
+	c.checkConn(err)
+	return code, msg, err
 }
 
 func (c *Ftp) getLine() (string, error) {
-	return c.textprotoConn.ReadLine()
+	line, err := c.textprotoConn.ReadLine()
+	return c.decode(line), err
+}
+
+// transferRequest opens the data connection for a command and wraps it for the caller.
+// t is nil for directory listings; for file transfers of remote a non-zero offset is sent
+// with REST before the command to restart the transfer there.
+func (c *Ftp) transferRequest(ctx context.Context, t *transferConfig, remote, format string, args ...interface{}) (*FtpDataConnector, error) {
+	var offset uint64
+	var ascii bool
+	var restore string
+	if t != nil {
+		offset = t.offset
+
+		var err error
+		if restore, err = c.switchType(ctx, t.dataType(remote)); err != nil {
+			return nil, err
+		}
+		ascii = c.asciiTransfer(t, remote)
+	}
+
+	conn, err := c.transferCmd(ctx, offset, format, args...)
+	if err != nil {
+		if restore != "" && !c.broken {
+			c.SendCmd(200, "TYPE %s", restore)
+		}
+		return nil, err
+	}
+
+	r := &FtpDataConnector{
+		conn:        conn,
+		c:           c,
+		ctx:         ctx,
+		stop:        c.watchContext(ctx, conn),
+		ascii:       ascii,
+		restoreType: restore,
+	}
+	if t != nil && t.progress != nil {
+		r.progress = newProgressTracker(t.progress, t.start, t.total)
+	}
+	if t != nil {
+		r.limiter = t.limiter
+	}
+	return r, nil
 }
 
-// getLines
-func (c *Ftp) getLines(r io.Reader) (lines []string, err error) {
-	scanner := bufio.NewScanner(r)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+// transferCmd
+func (c *Ftp) transferCmd(ctx context.Context, offset uint64, format string, args ...interface{}) (conn net.Conn, err error) {
+	if err = c.ensureConnected(ctx); err != nil {
+		return nil, err
 	}
-	if err = scanner.Err(); err != nil {
-		return lines, err
+
+	stop := c.watchContext(ctx, nil)
+	conn, err = c.openDataConn(ctx, offset, format, args...)
+	if stop() {
+		if conn != nil {
+			conn.Close()
+			if c.abortTransfer() == nil {
+				c.resync()
+			}
+		}
+		return nil, ctx.Err()
 	}
 	return
 }
 
-// transferCmd
-func (c *Ftp) transferCmd(format string, args ...interface{}) (conn net.Conn, err error) {
+// openDataConn
+func (c *Ftp) openDataConn(ctx context.Context, offset uint64, format string, args ...interface{}) (conn net.Conn, err error) {
 	var listener net.Listener
 	if c.passive {
 		host, port, err := c.makePasv()
@@ -467,7 +918,8 @@ func (c *Ftp) transferCmd(format string, args ...interface{}) (conn net.Conn, er
 			return nil, err
 		}
 
-		conn, err = net.DialTimeout("tcp4", net.JoinHostPort(host, strconv.Itoa(port)), c.timeout)
+		dialer := &net.Dialer{Timeout: c.timeout}
+		conn, err = dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
 		if err != nil {
 			return nil, err
 		}
@@ -479,16 +931,34 @@ func (c *Ftp) transferCmd(format string, args ...interface{}) (conn net.Conn, er
 		defer listener.Close()
 	}
 
-	code, msg, err := c.SendCmd(-1, format, args...)
+	// REST must immediately precede the transfer command
+	if offset > 0 {
+		_, _, err = c.sendCmd(350, "REST %d", offset)
+	}
+	if err == nil {
+		var code int
+		var msg string
+		code, msg, err = c.sendCmd(-1, format, args...)
+		if err == nil && code != 125 && code != 150 {
+			err = newFtpError(code, msg)
+		}
+	}
 	if err != nil {
+		if conn != nil {
+			conn.Close()
+		}
 		return nil, err
 	}
-	if code != 125 && code != 150 {
-		return nil, &textproto.Error{Code: code, Msg: msg}
-	}
 
 	if listener != nil {
-		conn, err = listener.Accept()
+		conn, err = acceptContext(ctx, listener)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if c.tlsData {
+		conn, err = c.tlsHandshake(conn)
 		if err != nil {
 			return nil, err
 		}
@@ -497,8 +967,150 @@ func (c *Ftp) transferCmd(format string, args ...interface{}) (conn net.Conn, er
 	return
 }
 
+// watchContext interrupts the pending I/O on the control connection and on the given
+// data connection when ctx is done. The returned stop function disarms the watch and
+// reports whether ctx fired, in which case the connection deadlines are cleared again.
+func (c *Ftp) watchContext(ctx context.Context, data net.Conn) (stop func() bool) {
+	if ctx.Done() == nil {
+		return func() bool { return false }
+	}
+
+	control := c.conn
+	done := make(chan struct{})
+	fired := make(chan bool, 1)
+	go func() {
+		select {
+		case <-ctx.Done():
+			control.SetDeadline(aLongTimeAgo)
+			if data != nil {
+				data.SetDeadline(aLongTimeAgo)
+			}
+			fired <- true
+		case <-done:
+			fired <- false
+		}
+	}()
+
+	return func() bool {
+		close(done)
+		if !<-fired {
+			return false
+		}
+		control.SetDeadline(time.Time{})
+		if data != nil {
+			data.SetDeadline(time.Time{})
+		}
+		return true
+	}
+}
+
+// aLongTimeAgo is a deadline in the past, used to unblock pending I/O immediately.
+var aLongTimeAgo = time.Unix(1, 0)
+
+// abortTransfer sends ABOR for an interrupted transfer and consumes the replies,
+// so that the control connection can be used again.
+func (c *Ftp) abortTransfer() error {
+	if c.timeout > 0 {
+		c.conn.SetDeadline(time.Now().Add(c.timeout))
+		defer c.conn.SetDeadline(time.Time{})
+	}
+
+	if err := c.putCmd("ABOR"); err != nil {
+		return err
+	}
+	for {
+		code, msg, err := c.getResponse(-1)
+		if err != nil {
+			return err
+		}
+		switch code {
+		case 225, 226:
+			// the control connection is in sync again
+			c.broken = false
+			return nil
+		case 125, 150, 425, 426, 451:
+			// the replies of the transfer, ABOR's own reply follows
+			continue
+		}
+		return newFtpError(code, msg)
+	}
+}
+
+// readTransferReply reads the final reply of a transfer within the session timeout.
+func (c *Ftp) readTransferReply() error {
+	if c.timeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(c.timeout))
+		defer c.conn.SetReadDeadline(time.Time{})
+	}
+
+	_, _, err := c.getResponse(226)
+	if isReply(err) {
+		// the control connection is in sync, whatever the reply
+		c.broken = false
+	}
+	return err
+}
+
+// resync skips the replies left on the control connection, e.g. when the server
+// answered ABOR after it had already completed the transfer with 226.
+func (c *Ftp) resync() error {
+	if err := c.putCmd("NOOP"); err != nil {
+		return err
+	}
+	for {
+		code, msg, err := c.getResponse(-1)
+		if err != nil {
+			return err
+		}
+		switch code {
+		case 200:
+			return nil
+		case 225, 226:
+			continue
+		}
+		return newFtpError(code, msg)
+	}
+}
+
+// acceptContext waits for the server to connect to listener, giving up when ctx is done.
+func acceptContext(ctx context.Context, listener net.Listener) (net.Conn, error) {
+	type accepted struct {
+		conn net.Conn
+		err  error
+	}
+
+	result := make(chan accepted, 1)
+	go func() {
+		conn, err := listener.Accept()
+		result <- accepted{conn, err}
+	}()
+
+	select {
+	case r := <-result:
+		return r.conn, r.err
+	case <-ctx.Done():
+		listener.Close()
+		if r := <-result; r.conn != nil {
+			r.conn.Close()
+		}
+		return nil, ctx.Err()
+	}
+}
+
 // makePasv
 func (c *Ftp) makePasv() (host string, port int, err error) {
+	if c.extended {
+		port, err = c.Epsv()
+		if err == nil {
+			host, _, err = net.SplitHostPort(c.conn.RemoteAddr().String())
+			return
+		}
+		if !isUnsupported(err) {
+			return
+		}
+		c.extended = false
+	}
+
 	_, line, err := c.SendCmd(227, "PASV")
 	if err != nil {
 		return
@@ -517,7 +1129,7 @@ func (c *Ftp) makePort() (net.Listener, error) {
 		return nil, err
 	}
 
-	newaddr := localaddr.IP.String() + ":0"
+	newaddr := net.JoinHostPort(localaddr.IP.String(), "0")
 	listenging := startListen(network, newaddr)
 	list := <-listenging
 	if list == nil {
@@ -525,8 +1137,29 @@ func (c *Ftp) makePort() (net.Listener, error) {
 	}
 
 	localaddr, err = net.ResolveTCPAddr(list.Addr().Network(), list.Addr().String())
+	if err != nil {
+		list.Close()
+		return nil, err
+	}
+
+	if c.extended {
+		err = c.Eprt(localaddr.IP.String(), localaddr.Port)
+		if !isUnsupported(err) {
+			if err != nil {
+				list.Close()
+				return nil, err
+			}
+			return list, nil
+		}
+		c.extended = false
+	}
+
 	err = c.Port(localaddr.IP.String(), localaddr.Port)
-	return list, err
+	if err != nil {
+		list.Close()
+		return nil, err
+	}
+	return list, nil
 }
 
 // startListen
@@ -563,6 +1196,24 @@ func parse227(msg string) (host string, port int, err error) {
 	return
 }
 
+// parse229
+func parse229(msg string) (port int, err error) {
+	start := strings.Index(msg, "(")
+	end := strings.LastIndex(msg, ")")
+	if start == -1 || end == -1 || end-start < 6 {
+		err = errors.New("No matching pattern for message: " + msg)
+		return
+	}
+
+	// (<d><d><d><port><d>)
+	fields := strings.Split(msg[start+1:end], msg[start+1:start+2])
+	if len(fields) != 5 {
+		err = errors.New("No matching pattern for message: " + msg)
+		return
+	}
+	return strconv.Atoi(fields[3])
+}
+
 // parse257
 func parse257(msg string) (string, error) {
 	start := strings.Index(msg, "\"")
@@ -0,0 +1,547 @@
+package ftpgo
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testServer is a minimal FTP server for the tests. It accepts PASV and, for FXP, PORT.
+type testServer struct {
+	t    *testing.T
+	ln   net.Listener
+	feat []string
+	// delay is the pause before the final reply of a transfer
+	delay time.Duration
+
+	mu sync.Mutex
+	// tls secures the sessions after AUTH TLS, or from the start when implicit is set
+	tls      *tls.Config
+	implicit bool
+	// files are served by SIZE, MLST and RETR and written by STOR and APPE
+	files map[string][]byte
+	// lists is the output of LIST, NLST and MLSD by argument
+	lists map[string]string
+	// dirs are the directories made of files and dirs, listed when lists has no entry
+	dirs map[string]bool
+	// replies overrides the reply to a command line ("SIZE /a") or a command ("SIZE"),
+	// an empty reply leaves the command unanswered
+	replies map[string]string
+	// once is like replies, for the next such command only
+	once map[string]string
+	// cmds logs the command lines received
+	cmds []string
+}
+
+// newTestServer starts a server advertising feat in FEAT, none when empty.
+func newTestServer(t *testing.T, feat ...string) *testServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testServer{
+		t:       t,
+		ln:      ln,
+		feat:    feat,
+		files:   map[string][]byte{},
+		lists:   map[string]string{},
+		dirs:    map[string]bool{},
+		replies: map[string]string{},
+		once:    map[string]string{},
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// dial connects and logs in a passive session.
+func (s *testServer) dial() *Ftp {
+	c, err := FtpConnect(s.ln.Addr().String(), 5*time.Second)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	if err := c.Login("user", "pass"); err != nil {
+		s.t.Fatal(err)
+	}
+	c.SetPasv(true)
+	s.t.Cleanup(func() { c.Quit() })
+	return c
+}
+
+// sent tells if the command line was received.
+func (s *testServer) sent(line string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, cmd := range s.cmds {
+		if cmd == line {
+			return true
+		}
+	}
+	return false
+}
+
+// serve
+func (s *testServer) serve(conn net.Conn) {
+	s.mu.Lock()
+	config, implicit := s.tls, s.implicit
+	s.mu.Unlock()
+	if implicit {
+		conn = tls.Server(conn, config)
+	}
+	defer func() { conn.Close() }()
+	r := bufio.NewReader(conn)
+	reply := func(format string, args ...interface{}) {
+		fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+
+	var pasv net.Listener
+	var port, from string
+	var rest int
+	prot := implicit
+	// accept connects to the address of PORT or waits for the passive data connection,
+	// protected after PROT P
+	accept := func() (dc net.Conn, err error) {
+		if port != "" {
+			dc, err = net.Dial("tcp", port)
+			port = ""
+		} else {
+			dc, err = pasv.Accept()
+			pasv.Close()
+		}
+		if err == nil && prot {
+			dc = tls.Server(dc, config)
+		}
+		return dc, err
+	}
+	reply("220 ready")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd, arg := line, ""
+		if i := strings.Index(line, " "); i != -1 {
+			cmd, arg = line[:i], line[i+1:]
+		}
+
+		s.mu.Lock()
+		s.cmds = append(s.cmds, line)
+		override, ok := s.replies[line]
+		if !ok {
+			override, ok = s.replies[cmd]
+		}
+		if next, found := s.once[cmd]; found {
+			override, ok = next, true
+			delete(s.once, cmd)
+		}
+		data, exists := s.files[arg]
+		list, listed := s.lists[arg]
+		s.mu.Unlock()
+		if ok {
+			if override != "" {
+				reply("%s", override)
+			}
+			continue
+		}
+
+		switch cmd {
+		case "AUTH":
+			if config == nil {
+				reply("502 not implemented")
+				continue
+			}
+			reply("234 proceed")
+			conn = tls.Server(conn, config)
+			r = bufio.NewReader(conn)
+		case "PBSZ":
+			reply("200 ok")
+		case "PROT":
+			prot = arg == "P"
+			reply("200 ok")
+		case "USER":
+			reply("331 password required")
+		case "PASS":
+			reply("230 logged in")
+		case "FEAT":
+			if len(s.feat) == 0 {
+				reply("502 not implemented")
+				continue
+			}
+			reply("211-Features:")
+			for _, f := range s.feat {
+				reply(" %s", f)
+			}
+			reply("211 End")
+		case "TYPE", "NOOP", "OPTS":
+			reply("200 ok")
+		case "PASV":
+			if pasv, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+				reply("425 %v", err)
+				continue
+			}
+			port := pasv.Addr().(*net.TCPAddr).Port
+			reply("227 Entering Passive Mode (127,0,0,1,%d,%d)", port>>8, port&0xff)
+		case "PORT":
+			var h [6]int
+			if n, _ := fmt.Sscanf(arg, "%d,%d,%d,%d,%d,%d", &h[0], &h[1], &h[2], &h[3], &h[4], &h[5]); n != 6 {
+				reply("501 bad PORT")
+				continue
+			}
+			port = fmt.Sprintf("%d.%d.%d.%d:%d", h[0], h[1], h[2], h[3], h[4]<<8|h[5])
+			reply("200 PORT ok")
+		case "SIZE":
+			if !exists {
+				reply("550 %s: No such file", arg)
+				continue
+			}
+			reply("213 %d", len(data))
+		case "REST":
+			rest, _ = strconv.Atoi(arg)
+			reply("350 restarting at %d", rest)
+		case "RETR":
+			if !exists {
+				reply("550 %s: No such file", arg)
+				continue
+			}
+			if rest > len(data) {
+				rest = len(data)
+			}
+			s.send(reply, accept, data[rest:])
+			rest = 0
+		case "LIST", "NLST", "MLSD":
+			if !listed {
+				s.mu.Lock()
+				list, listed = s.listDir(cmd, arg)
+				s.mu.Unlock()
+			}
+			if !listed {
+				reply("550 %s: No such directory", arg)
+				continue
+			}
+			s.send(reply, accept, []byte(list))
+		case "MLST":
+			s.mu.Lock()
+			isDir := s.dirs[arg]
+			s.mu.Unlock()
+			switch {
+			case exists:
+				reply("250-Listing %s", arg)
+				reply(" type=file;size=%d;modify=20200102030405; %s", len(data), arg)
+			case isDir:
+				reply("250-Listing %s", arg)
+				reply(" type=dir;modify=20200102030405; %s", arg)
+			default:
+				reply("550 %s: No such file or directory", arg)
+				continue
+			}
+			reply("250 End")
+		case "STOR", "APPE":
+			reply("150 ok")
+			dc, err := accept()
+			if err != nil {
+				reply("425 %v", err)
+				continue
+			}
+			b, err := io.ReadAll(dc)
+			dc.Close()
+			if err != nil {
+				reply("426 %v", err)
+				continue
+			}
+			s.mu.Lock()
+			if cmd == "APPE" {
+				b = append(s.files[arg], b...)
+			}
+			s.files[arg] = b
+			s.mu.Unlock()
+			reply("226 done")
+		case "RNFR":
+			from = arg
+			reply("350 ready")
+		case "RNTO":
+			s.mu.Lock()
+			b, ok := s.files[from]
+			if ok {
+				delete(s.files, from)
+				s.files[arg] = b
+			}
+			s.mu.Unlock()
+			if !ok {
+				reply("550 %s: No such file", from)
+				continue
+			}
+			reply("250 renamed")
+		case "MKD":
+			s.mu.Lock()
+			_, isFile := s.files[arg]
+			ok := !isFile && !s.dirs[arg] && (path.Dir(arg) == "/" || s.dirs[path.Dir(arg)])
+			if ok {
+				s.dirs[arg] = true
+			}
+			s.mu.Unlock()
+			if !ok {
+				reply("550 Create directory operation failed.")
+				continue
+			}
+			reply("257 \"%s\" created", arg)
+		case "RMD":
+			s.mu.Lock()
+			ok := s.dirs[arg]
+			delete(s.dirs, arg)
+			s.mu.Unlock()
+			if !ok {
+				reply("550 Remove directory operation failed.")
+				continue
+			}
+			reply("250 removed")
+		case "DELE":
+			s.mu.Lock()
+			delete(s.files, arg)
+			s.mu.Unlock()
+			if !exists {
+				reply("550 %s: No such file", arg)
+				continue
+			}
+			reply("250 deleted")
+		case "ABOR":
+			// the transfers are over once their reply is sent
+			reply("225 No transfer to ABOR")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 %s not implemented", cmd)
+		}
+	}
+}
+
+// listDir lists the directory made of files and dirs in the output format of cmd.
+func (s *testServer) listDir(cmd, dir string) (string, bool) {
+	if !s.dirs[dir] {
+		return "", false
+	}
+
+	var lines []string
+	for name, data := range s.files {
+		if path.Dir(name) != dir {
+			continue
+		}
+		switch cmd {
+		case "LIST":
+			lines = append(lines, fmt.Sprintf("-rw-r--r--  1 ftp ftp %8d Jan 02  2020 %s", len(data), path.Base(name)))
+		case "MLSD":
+			lines = append(lines, fmt.Sprintf("type=file;size=%d;modify=20200102030405; %s", len(data), path.Base(name)))
+		default:
+			lines = append(lines, name)
+		}
+	}
+	for name := range s.dirs {
+		if name == dir || path.Dir(name) != dir {
+			continue
+		}
+		switch cmd {
+		case "LIST":
+			lines = append(lines, fmt.Sprintf("drwxr-xr-x  2 ftp ftp     4096 Jan 02  2020 %s", path.Base(name)))
+		case "MLSD":
+			lines = append(lines, fmt.Sprintf("type=dir;modify=20200102030405; %s", path.Base(name)))
+		default:
+			lines = append(lines, name)
+		}
+	}
+	sort.Strings(lines)
+
+	var list strings.Builder
+	for _, line := range lines {
+		list.WriteString(line + "\r\n")
+	}
+	return list.String(), true
+}
+
+// send writes data on the passive data connection, replying 426 when the client closes it early.
+func (s *testServer) send(reply func(string, ...interface{}), accept func() (net.Conn, error), data []byte) {
+	reply("150 opening data connection")
+	dc, err := accept()
+	if err != nil {
+		reply("425 %v", err)
+		return
+	}
+	_, err = dc.Write(data)
+	dc.Close()
+	time.Sleep(s.delay)
+	if err != nil {
+		reply("426 %v", err)
+		return
+	}
+	reply("226 done")
+}
+
+func TestCancelledTransferResync(t *testing.T) {
+	s := newTestServer(t)
+	s.files["/f"] = []byte("0123456789")
+	// the 226 is not read together with the 150
+	s.delay = 20 * time.Millisecond
+	c := s.dial()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r, err := c.RetrRequestContext(ctx, "/f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(r); err != nil {
+		t.Fatal(err)
+	}
+	// the server has sent 226, the transfer is cancelled anyway
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	r.Close()
+
+	for i := 0; i < 2; i++ {
+		if size, err := c.Size("/f"); err != nil || size != 10 {
+			t.Fatalf("Size = %d, %v", size, err)
+		}
+	}
+}
+
+func TestDirFileWithMlsd(t *testing.T) {
+	s := newTestServer(t, "MLST type*;size*;modify*;")
+	s.replies["MLSD /file.txt"] = "501 /file.txt: Not a directory"
+	s.lists["/file.txt"] = "-rw-r--r--  1 ftp ftp   12 Jan 02 2020 file.txt\r\n"
+	s.lists["/pub"] = "type=dir;modify=20200102030405; docs\r\n" +
+		"type=file;size=12;modify=20200102030405; a.txt\r\n"
+	c := s.dial()
+
+	infos, err := c.Dir("/file.txt")
+	if err != nil || len(infos) != 1 || infos[0].Name() != "file.txt" || infos[0].Size() != 12 {
+		t.Fatalf("Dir(file) = %v, %v", infos, err)
+	}
+
+	it, err := c.DirIter(context.Background(), "/file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !it.Next() || it.Entry().Name() != "file.txt" || it.Close() != nil {
+		t.Fatalf("DirIter(file) = %v, %v", it.Entry(), it.Err())
+	}
+
+	infos, err = c.Dir("/pub")
+	if err != nil || len(infos) != 2 || infos[0].Fact("modify") == "" {
+		t.Fatalf("Dir(dir) = %v, %v", infos, err)
+	}
+	if s.sent("LIST /pub") {
+		t.Fatal("LIST used for a directory")
+	}
+
+	if _, err := c.Dir("/missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Dir(missing) = %v", err)
+	}
+}
+
+func TestParsePassiveReplies(t *testing.T) {
+	tests229 := []struct {
+		msg  string
+		port int
+		ok   bool
+	}{
+		{"Entering Extended Passive Mode (|||6446|)", 6446, true},
+		{"Entering Extended Passive Mode (!!!6446!)", 6446, true},
+		{"EPSV ok (|||21|) at your service", 21, true},
+		{"Entering Extended Passive Mode", 0, false},
+		{"Entering Extended Passive Mode (|||6446)", 0, false},
+		{"Entering Extended Passive Mode (||||)", 0, false},
+		{"Entering Extended Passive Mode (|||port|)", 0, false},
+		{"Entering Extended Passive Mode (|1|2|3|4|)", 0, false},
+		{"Entering Extended Passive Mode )|||6446|(", 0, false},
+	}
+	for _, tt := range tests229 {
+		port, err := parse229(tt.msg)
+		if (err == nil) != tt.ok || port != tt.port {
+			t.Errorf("parse229(%q) = %d, %v", tt.msg, port, err)
+		}
+	}
+
+	tests227 := []struct {
+		msg  string
+		host string
+		port int
+		ok   bool
+	}{
+		{"Entering Passive Mode (192,168,1,2,25,46)", "192.168.1.2", 25<<8 + 46, true},
+		{"Entering Passive Mode 10,0,0,1,0,21", "10.0.0.1", 21, true},
+		{"Entering Passive Mode (192,168,1,2,25)", "", 0, false},
+		{"Entering Passive Mode", "", 0, false},
+	}
+	for _, tt := range tests227 {
+		host, port, err := parse227(tt.msg)
+		if (err == nil) != tt.ok || host != tt.host || port != tt.port {
+			t.Errorf("parse227(%q) = %s, %d, %v", tt.msg, host, port, err)
+		}
+	}
+}
+
+func TestModTime(t *testing.T) {
+	s := newTestServer(t, "MDTM", "MFMT")
+	s.replies["MDTM /f"] = "213 20200102030405"
+	s.replies["MDTM /frac"] = "213 20200102030405.250"
+	s.replies["MDTM /bad"] = "213 yesterday"
+	s.replies["MFMT"] = "213 Modify=20210304050607; /f"
+	c := s.dial()
+
+	tests := []struct {
+		path string
+		want time.Time
+		ok   bool
+	}{
+		{"/f", time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), true},
+		{"/frac", time.Date(2020, 1, 2, 3, 4, 5, 250e6, time.UTC), true},
+		{"/bad", time.Time{}, false},
+		{"/missing", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		mtime, err := c.Mdtm(tt.path)
+		if (err == nil) != tt.ok || !mtime.Equal(tt.want) {
+			t.Errorf("Mdtm(%s) = %v, %v", tt.path, mtime, err)
+		}
+	}
+
+	// the time is sent in UTC whatever its location
+	mtime := time.Date(2021, 3, 4, 14, 6, 7, 0, time.FixedZone("JST", 9*3600))
+	if err := c.SetModTime("/f", mtime); err != nil || !s.sent("MFMT 20210304050607 /f") {
+		t.Fatalf("SetModTime with MFMT = %v", err)
+	}
+
+	// without MFMT the MDTM variant is used
+	s = newTestServer(t)
+	s.replies["MDTM"] = "213 ok"
+	c = s.dial()
+	if err := c.SetModTime("/f", mtime); err != nil || !s.sent("MDTM 20210304050607 /f") {
+		t.Fatalf("SetModTime with MDTM = %v", err)
+	}
+	s.mu.Lock()
+	s.replies["MDTM"] = "550 /f: Permission denied"
+	s.mu.Unlock()
+	if err := c.SetModTime("/f", mtime); !errors.Is(err, ErrPermission) {
+		t.Fatalf("SetModTime refused = %v", err)
+	}
+}
@@ -0,0 +1,300 @@
+package ftpgo
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// Checksum algorithms, named as in the HASH command.
+const (
+	HashCRC32  = "CRC32"
+	HashMD5    = "MD5"
+	HashSHA1   = "SHA-1"
+	HashSHA256 = "SHA-256"
+	HashSHA512 = "SHA-512"
+)
+
+// ErrChecksumMismatch is returned when a file transferred with WithVerifyHash differs from the server's copy.
+var ErrChecksumMismatch = errors.New("Checksum mismatch")
+
+// hashPreference lists the algorithms from the most to the least preferred.
+var hashPreference = []string{HashSHA256, HashSHA512, HashSHA1, HashMD5, HashCRC32}
+
+// xHashCommands are the non-standard commands computing a checksum, by algorithm.
+var xHashCommands = map[string]string{
+	HashCRC32:  "XCRC",
+	HashMD5:    "XMD5",
+	HashSHA1:   "XSHA1",
+	HashSHA256: "XSHA256",
+	HashSHA512: "XSHA512",
+}
+
+// Hash issues a HASH FTP command (draft-bryan-ftpext-hash), which returns the checksum of
+// the whole file with the algorithm currently selected on the server. The algorithm and
+// the lowercase hexadecimal checksum are returned.
+// ftp server extension command.
+func (c *Ftp) Hash(path string) (algo, sum string, err error) {
+	_, msg, err := c.SendCmd(213, "HASH %s", path)
+	if err != nil {
+		return "", "", err
+	}
+
+	// 213 SHA-256 0-49 169cd22282da7f147cb491e559e9dd filename
+	fields := strings.Fields(msg)
+	if len(fields) < 3 || !isHex(fields[2]) {
+		return "", "", errors.New("Invalid HASH response: " + msg)
+	}
+	return fields[0], strings.ToLower(fields[2]), nil
+}
+
+// SetHashAlgorithm selects the algorithm of the HASH command with OPTS HASH.
+// ftp server extension command.
+func (c *Ftp) SetHashAlgorithm(algo string) error {
+	_, _, err := c.SendCmd(200, "OPTS HASH %s", algo)
+	if err == nil {
+		c.hashAlgo = algo
+	}
+	return err
+}
+
+// Xcrc issues a XCRC FTP command, which returns the CRC32 checksum of the file.
+// ftp server extension command.
+func (c *Ftp) Xcrc(path string) (string, error) {
+	sum, err := c.xhash("XCRC", path)
+	return normalizeSum(HashCRC32, sum), err
+}
+
+// Xmd5 issues a XMD5 FTP command, which returns the MD5 checksum of the file.
+// ftp server extension command.
+func (c *Ftp) Xmd5(path string) (string, error) {
+	return c.xhash("XMD5", path)
+}
+
+// Xsha1 issues a XSHA1 FTP command, which returns the SHA-1 checksum of the file.
+// ftp server extension command.
+func (c *Ftp) Xsha1(path string) (string, error) {
+	return c.xhash("XSHA1", path)
+}
+
+// Xsha256 issues a XSHA256 FTP command, which returns the SHA-256 checksum of the file.
+// ftp server extension command.
+func (c *Ftp) Xsha256(path string) (string, error) {
+	return c.xhash("XSHA256", path)
+}
+
+// xhash issues one of the X* checksum commands and returns the lowercase hexadecimal checksum.
+func (c *Ftp) xhash(cmd, remote string) (string, error) {
+	code, msg, err := c.SendCmd(-1, "%s %s", cmd, remote)
+	if err != nil {
+		return "", err
+	}
+	if code != 250 && code != 213 {
+		return "", newFtpError(code, msg)
+	}
+
+	// servers reply "250 <sum>", some add the file name before or after it; the name
+	// may look like a checksum too, so the sum is found by its position
+	msg = strings.TrimSpace(msg)
+	for _, name := range []string{remote, path.Base(remote)} {
+		if trimmed := strings.TrimSuffix(msg, " "+name); trimmed != msg {
+			msg = trimmed
+			break
+		}
+	}
+	fields := strings.Fields(msg)
+	if len(fields) == 0 || !isHex(fields[len(fields)-1]) {
+		return "", errors.New("Invalid " + cmd + " response: " + msg)
+	}
+	return strings.ToLower(fields[len(fields)-1]), nil
+}
+
+// normalizeSum returns the checksum with the leading zeros some servers leave out of CRC32 sums.
+func normalizeSum(algo, sum string) string {
+	if algo == HashCRC32 && sum != "" && len(sum) < 8 {
+		sum = strings.Repeat("0", 8-len(sum)) + sum
+	}
+	return sum
+}
+
+// HashAlgorithms returns the checksum algorithms the server advertises in FEAT,
+// with HASH or the X* commands, from the most to the least preferred.
+func (c *Ftp) HashAlgorithms() []string {
+	var algos []string
+	for _, algo := range hashPreference {
+		if c.Features().HasParam("HASH", algo) || c.hasFeature(xHashCommands[algo]) {
+			algos = append(algos, algo)
+		}
+	}
+	return algos
+}
+
+// Checksum returns the lowercase hexadecimal checksum of the remote file computed by the
+// server with algo, using HASH when FEAT lists the algorithm and otherwise the matching
+// X* command. An empty algo uses the most preferred of HashAlgorithms.
+// The X* commands are also tried when the server does not support FEAT.
+func (c *Ftp) Checksum(path, algo string) (string, error) {
+	algo, err := c.hashAlgorithm(algo)
+	if err != nil {
+		return "", err
+	}
+
+	if c.Features().HasParam("HASH", algo) {
+		if !strings.EqualFold(c.currentHashAlgorithm(), algo) {
+			if err := c.SetHashAlgorithm(algo); err != nil {
+				return "", err
+			}
+		}
+		used, sum, err := c.Hash(path)
+		if err != nil {
+			return "", err
+		}
+		if !strings.EqualFold(used, algo) {
+			return "", fmt.Errorf("HASH used %s instead of %s", used, algo)
+		}
+		return normalizeSum(algo, sum), nil
+	}
+
+	sum, err := c.xhash(xHashCommands[algo], path)
+	return normalizeSum(algo, sum), err
+}
+
+// hashAlgorithm returns the canonical name of algo, or the preferred algorithm of the server for "".
+func (c *Ftp) hashAlgorithm(algo string) (string, error) {
+	if algo == "" {
+		algos := c.HashAlgorithms()
+		if len(algos) == 0 {
+			if len(c.Features()) == 0 {
+				// no FEAT, try the most common extension
+				return HashMD5, nil
+			}
+			return "", fmt.Errorf("%w: no checksum command", ErrUnsupported)
+		}
+		return algos[0], nil
+	}
+
+	for _, name := range hashPreference {
+		if strings.EqualFold(algo, name) || strings.EqualFold(algo, strings.Replace(name, "-", "", 1)) {
+			if !c.Features().HasParam("HASH", name) && !c.hasFeature(xHashCommands[name]) && len(c.Features()) > 0 {
+				return "", fmt.Errorf("%w: %s checksums", ErrUnsupported, name)
+			}
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s checksums", ErrUnsupported, algo)
+}
+
+// currentHashAlgorithm returns the algorithm HASH uses, which FEAT marks with a '*'.
+func (c *Ftp) currentHashAlgorithm() string {
+	if c.hashAlgo != "" {
+		return c.hashAlgo
+	}
+	for _, algo := range c.Features().Params("HASH") {
+		if strings.HasSuffix(algo, "*") {
+			return strings.TrimSuffix(algo, "*")
+		}
+	}
+	return ""
+}
+
+// WithVerifyHash makes RetrFile and StorFile compute the checksum of the file while it is
+// transferred and compare it with the one computed by the server (see Checksum), failing
+// with ErrChecksumMismatch when they differ. An empty algo uses the preferred algorithm of
+// the server. Resumed transfers checksum the whole local file afterwards.
+// ASCII transfers cannot be verified.
+func WithVerifyHash(algo string) TransferOption {
+	return func(t *transferConfig) {
+		t.verifyHash, t.hashAlgo = true, algo
+	}
+}
+
+// newTransferHash returns the hash computing the checksum of the transfer of remote,
+// or nil when the transfer is not verified.
+func (c *Ftp) newTransferHash(t *transferConfig, remote string) (hash.Hash, error) {
+	if !t.verifyHash {
+		return nil, nil
+	}
+	if c.asciiTransfer(t, remote) {
+		return nil, errors.New("ASCII transfers cannot be checksummed: " + remote)
+	}
+
+	algo, err := c.hashAlgorithm(t.hashAlgo)
+	if err != nil {
+		return nil, err
+	}
+	t.hashAlgo = algo
+	return newHash(algo), nil
+}
+
+// verifyHash compares sum, the checksum of the local copy, with the checksum of remote.
+func (c *Ftp) verifyHash(t *transferConfig, remote string, sum []byte) error {
+	remoteSum, err := c.Checksum(remote, t.hashAlgo)
+	if err != nil {
+		return err
+	}
+
+	expected, _ := hex.DecodeString(remoteSum)
+	if !bytes.Equal(sum, expected) {
+		return fmt.Errorf("%w for %s: %s %x, expected %s", ErrChecksumMismatch, remote, t.hashAlgo, sum, remoteSum)
+	}
+	return nil
+}
+
+// verifyFileHash checksums the whole local file and compares it with the checksum of remote,
+// when the transfer is verified.
+func (c *Ftp) verifyFileHash(t *transferConfig, local, remote string) error {
+	h, err := c.newTransferHash(t, remote)
+	if err != nil || h == nil {
+		return err
+	}
+
+	file, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(h, file); err != nil {
+		return err
+	}
+	return c.verifyHash(t, remote, h.Sum(nil))
+}
+
+// newHash
+func newHash(algo string) hash.Hash {
+	switch algo {
+	case HashCRC32:
+		return crc32.NewIEEE()
+	case HashMD5:
+		return md5.New()
+	case HashSHA1:
+		return sha1.New()
+	case HashSHA512:
+		return sha512.New()
+	}
+	return sha256.New()
+}
+
+// isHex
+func isHex(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+package ftpgo
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestXhashReplies(t *testing.T) {
+	s := newTestServer(t)
+	const sum = "5d41402abc4b2a76b9719d911017c592"
+	s.replies["XMD5 /f"] = "250 5D41402ABC4B2A76B9719D911017C592"
+	s.replies["XMD5 /pub/cafe1234cafe"] = "250 /pub/cafe1234cafe " + sum
+	s.replies["XMD5 cafe5678cafe"] = "250 cafe5678cafe " + sum
+	s.replies["XMD5 /pub/deadbeef00"] = "250 " + sum + " deadbeef00"
+	s.replies["XMD5 /pub/0123456789"] = "250 " + sum + " /pub/0123456789"
+	s.replies["XMD5 /bad"] = "250 /bad"
+	s.replies["XCRC /f"] = "250 EFAB1D3"
+	s.replies["XMD5 /missing"] = "550 /missing: No such file"
+	c := s.dial()
+
+	for _, name := range []string{"/f", "/pub/cafe1234cafe", "cafe5678cafe", "/pub/deadbeef00", "/pub/0123456789"} {
+		if got, err := c.Xmd5(name); err != nil || got != sum {
+			t.Errorf("Xmd5(%s) = %q, %v", name, got, err)
+		}
+	}
+	if got, err := c.Xmd5("/bad"); err == nil {
+		t.Errorf("Xmd5 without a sum = %q", got)
+	}
+	if _, err := c.Xmd5("/missing"); !hasCode(err, 550) {
+		t.Errorf("Xmd5(missing) = %v", err)
+	}
+	if got, err := c.Xcrc("/f"); err != nil || got != "0efab1d3" {
+		t.Errorf("Xcrc = %q, %v", got, err)
+	}
+}
+
+func TestHashReplies(t *testing.T) {
+	s := newTestServer(t, "HASH SHA-256*;MD5")
+	const sum = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	s.replies["HASH /f"] = "213 SHA-256 0-5 " + sum + " /f"
+	s.replies["HASH /g"] = "213 MD5 0-5 5d41402abc4b2a76b9719d911017c592 /g"
+	s.replies["HASH /bad"] = "213 SHA-256 0-5 /bad"
+	c := s.dial()
+
+	if algo, got, err := c.Hash("/f"); err != nil || algo != HashSHA256 || got != sum {
+		t.Errorf("Hash = %s %q, %v", algo, got, err)
+	}
+	if _, _, err := c.Hash("/bad"); err == nil {
+		t.Error("Hash without a sum succeeded")
+	}
+	if got, err := c.Checksum("/f", "sha256"); err != nil || got != sum {
+		t.Errorf("Checksum = %q, %v", got, err)
+	}
+	if s.sent("OPTS HASH SHA-256") {
+		t.Error("the current algorithm selected again")
+	}
+	// the server answers with another algorithm than the one selected
+	if _, err := c.Checksum("/g", HashSHA256); err == nil {
+		t.Error("Checksum accepted a MD5 sum")
+	}
+	if _, err := c.Checksum("/f", HashSHA1); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Checksum(SHA-1) = %v", err)
+	}
+}
+
+func TestVerifyHashShortCRC(t *testing.T) {
+	// data whose CRC32 has a leading zero digit
+	var data []byte
+	for i := 0; ; i++ {
+		data = []byte(fmt.Sprintf("data %d", i))
+		if crc32.ChecksumIEEE(data) < 1<<28 {
+			break
+		}
+	}
+
+	s := newTestServer(t)
+	s.files["/f"] = data
+	s.replies["XCRC /f"] = fmt.Sprintf("250 %X", crc32.ChecksumIEEE(data))
+	c := s.dial()
+
+	local := filepath.Join(t.TempDir(), "f")
+	if err := c.RetrFile("/f", local, WithVerifyHash(HashCRC32)); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.ReadFile(local); err != nil || string(got) != string(data) {
+		t.Fatalf("read %q, %v", got, err)
+	}
+
+	s.mu.Lock()
+	s.replies["XCRC /f"] = "250 1234"
+	s.mu.Unlock()
+	if err := c.RetrFile("/f", local, WithVerifyHash(HashCRC32)); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("RetrFile with a wrong sum = %v", err)
+	}
+}
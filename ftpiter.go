@@ -0,0 +1,191 @@
+package ftpgo
+
+import (
+	"bufio"
+	"context"
+	"strings"
+)
+
+// DirIterator reads a directory listing entry by entry while it arrives on the data
+// connection, instead of holding the whole listing in memory:
+//
+//	it, err := c.DirIter(ctx, "/pub")
+//	if err != nil {
+//		return err
+//	}
+//	defer it.Close()
+//	for it.Next() {
+//		fmt.Println(it.Entry().Name())
+//	}
+//	return it.Err()
+//
+// The data connection is closed and the final reply read when the listing ends, or by
+// Close when the iteration stops early. A listing cannot be retried once started, so
+// a retry policy only applies to the opening of the data connection.
+type DirIterator struct {
+	c       *Ftp
+	r       *FtpDataConnector
+	scanner *bufio.Scanner
+	// parse turns a line into an entry; skip is true for the lines which are not entries
+	parse func(line string) (entry *FtpFile, skip bool, err error)
+	line  string
+	entry *FtpFile
+	err   error
+	done  bool
+}
+
+// DirIter lists the directory like DirContext, with MLSD when the server supports it
+// and LIST otherwise, yielding the parsed entries.
+func (c *Ftp) DirIter(ctx context.Context, args ...string) (*DirIterator, error) {
+	if c.useMlsd(args) {
+		it, err := c.MlsdIter(ctx, strings.Join(args, ""))
+		if !mlsdRefused(err) {
+			return it, err
+		}
+	}
+	return c.openIter(ctx, c.dirIter, args...)
+}
+
+// MlsdIter lists the directory with MLSD, yielding the entries but the directory itself and its parent.
+func (c *Ftp) MlsdIter(ctx context.Context, path string) (*DirIterator, error) {
+	return c.openIter(ctx, c.mlsdIter, path)
+}
+
+// ListIter issues a LIST FTP command, yielding every line with Text. Entry is the parsed
+// line, or nil for the lines which do not describe a file, e.g. "total 12".
+func (c *Ftp) ListIter(ctx context.Context, args ...string) (*DirIterator, error) {
+	return c.openIter(ctx, c.listIter, args...)
+}
+
+// NlstIter issues an NLST FTP command, yielding every name with Text.
+// Entry only has the name.
+func (c *Ftp) NlstIter(ctx context.Context, args ...string) (*DirIterator, error) {
+	return c.openIter(ctx, c.nlstIter, args...)
+}
+
+// openIter opens a listing with one of the iterator constructors below, retried
+// with the policy of the session.
+func (c *Ftp) openIter(ctx context.Context,
+	open func(ctx context.Context, args ...string) (*DirIterator, error), args ...string) (it *DirIterator, err error) {
+	err = c.retryContext(ctx, func() (err error) {
+		it, err = open(ctx, args...)
+		return
+	})
+	return
+}
+
+// dirIter
+func (c *Ftp) dirIter(ctx context.Context, args ...string) (*DirIterator, error) {
+	return c.newIter(ctx, "LIST", args, func(line string) (*FtpFile, bool, error) {
+		fileinfo, err := NewFtpFile(line)
+		return fileinfo, err != nil, nil
+	})
+}
+
+// mlsdIter
+func (c *Ftp) mlsdIter(ctx context.Context, args ...string) (*DirIterator, error) {
+	if len(args) == 1 && args[0] == "" {
+		args = nil
+	}
+	return c.newIter(ctx, "MLSD", args, func(line string) (*FtpFile, bool, error) {
+		fileinfo, err := ParseMlsxFormat(line)
+		if err != nil {
+			return nil, false, err
+		}
+		switch strings.ToLower(fileinfo.Fact("type")) {
+		case "cdir", "pdir":
+			return nil, true, nil
+		}
+		return fileinfo, false, nil
+	})
+}
+
+// listIter
+func (c *Ftp) listIter(ctx context.Context, args ...string) (*DirIterator, error) {
+	return c.newIter(ctx, "LIST", args, func(line string) (*FtpFile, bool, error) {
+		fileinfo, _ := NewFtpFile(line)
+		return fileinfo, false, nil
+	})
+}
+
+// nlstIter
+func (c *Ftp) nlstIter(ctx context.Context, args ...string) (*DirIterator, error) {
+	return c.newIter(ctx, "NLST", args, func(line string) (*FtpFile, bool, error) {
+		return &FtpFile{name: line}, false, nil
+	})
+}
+
+// newIter issues the listing command and returns the iterator over its data connection.
+func (c *Ftp) newIter(ctx context.Context, cmd string, args []string,
+	parse func(line string) (*FtpFile, bool, error)) (*DirIterator, error) {
+	r, err := c.transferRequest(ctx, nil, "", "%s", strings.Join(append([]string{cmd}, args...), " "))
+	if err != nil {
+		return nil, err
+	}
+
+	return &DirIterator{c: c, r: r, scanner: bufio.NewScanner(r), parse: parse}, nil
+}
+
+// Next advances to the next entry, reading the listing as needed. It returns false at
+// the end of the listing or on error, see Err; the data connection is then closed.
+func (it *DirIterator) Next() bool {
+	for !it.done {
+		if !it.scanner.Scan() {
+			it.finish(it.scanner.Err())
+			return false
+		}
+
+		line := it.c.decode(it.scanner.Text())
+		entry, skip, err := it.parse(line)
+		if err != nil {
+			it.finish(err)
+			return false
+		}
+		if !skip {
+			it.line, it.entry = line, entry
+			return true
+		}
+	}
+	return false
+}
+
+// Entry returns the current entry.
+func (it *DirIterator) Entry() *FtpFile {
+	return it.entry
+}
+
+// Text returns the current line of the listing.
+func (it *DirIterator) Text() string {
+	return it.line
+}
+
+// Err returns the error which ended the listing, nil at its normal end.
+func (it *DirIterator) Err() error {
+	return it.err
+}
+
+// Close ends the listing. When it stops before the end the data connection is closed
+// and the reply to the aborted transfer is read, so the session can be used again.
+func (it *DirIterator) Close() error {
+	if !it.done {
+		it.done = true
+		it.line, it.entry = "", nil
+		err := it.r.Close()
+		// the server replies that the transfer was cut short, or completed when it had sent everything
+		if err != nil && !hasCode(err, StatusTransferAborted, StatusCanNotOpenDataConnection, StatusActionAborted) {
+			it.err = err
+		}
+	}
+	return it.err
+}
+
+// finish records err and closes the data connection at the end of the listing.
+func (it *DirIterator) finish(err error) {
+	it.done = true
+	it.line, it.entry = "", nil
+	cerr := it.r.Close()
+	if err == nil {
+		err = cerr
+	}
+	it.err = err
+}
@@ -0,0 +1,99 @@
+package ftpgo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestListIterShortLines(t *testing.T) {
+	s := newTestServer(t)
+	s.lists["/pub"] = "total 12\r\n" +
+		"drwxr-xr-x  2 ftp ftp 4096 Jan 02 2020 docs\r\n" +
+		"-rw-r--r--  1 ftp ftp   12 Jan 02 2020 a.txt\r\n" +
+		"x\r\n"
+	c := s.dial()
+
+	it, err := c.ListIter(context.Background(), "/pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+
+	var lines []string
+	var names []string
+	for it.Next() {
+		lines = append(lines, it.Text())
+		if entry := it.Entry(); entry != nil {
+			names = append(names, entry.Name())
+		}
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 4 || lines[0] != "total 12" {
+		t.Fatalf("lines = %q", lines)
+	}
+	if len(names) != 2 || names[0] != "docs" || names[1] != "a.txt" {
+		t.Fatalf("names = %q", names)
+	}
+
+	infos, err := c.Dir("/pub")
+	if err != nil || len(infos) != 2 {
+		t.Fatalf("Dir = %v, %v", infos, err)
+	}
+	if list, err := c.List("/pub"); err != nil || len(list) != 4 {
+		t.Fatalf("List = %q, %v", list, err)
+	}
+}
+
+func TestNewFtpFileShortLines(t *testing.T) {
+	for _, line := range []string{"", "x", "total 12", "01-02-20", "d a b c d e f g h"} {
+		if _, err := NewFtpFile(line); err == nil {
+			t.Errorf("NewFtpFile(%q) succeeded", line)
+		}
+	}
+}
+
+func TestDirIterEarlyClose(t *testing.T) {
+	s := newTestServer(t)
+	// larger than the socket buffers, so the server cannot send it all before Close
+	var list strings.Builder
+	for i := 0; i < 200000; i++ {
+		fmt.Fprintf(&list, "-rw-r--r--  1 ftp ftp %8d Jan 02 2020 file%06d.txt\r\n", i, i)
+	}
+	s.lists["/big"] = list.String()
+	s.files["/f"] = []byte("0123456789")
+	c := s.dial()
+
+	for _, open := range []func(ctx context.Context, args ...string) (*DirIterator, error){c.DirIter, c.ListIter} {
+		it, err := open(context.Background(), "/big")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 3; i++ {
+			if !it.Next() {
+				t.Fatalf("Next = false: %v", it.Err())
+			}
+		}
+		if name := it.Entry().Name(); name != "file000002.txt" {
+			t.Fatalf("Entry = %q", name)
+		}
+
+		if err := it.Close(); err != nil {
+			t.Fatalf("Close = %v", err)
+		}
+		if it.Next() || it.Entry() != nil || it.Text() != "" {
+			t.Fatal("Next after Close")
+		}
+		if err := it.Close(); err != nil {
+			t.Fatalf("second Close = %v", err)
+		}
+
+		// the session is usable again
+		if size, err := c.Size("/f"); err != nil || size != 10 {
+			t.Fatalf("Size = %d, %v", size, err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package ftpgo
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter caps the bandwidth of data connections with a token bucket.
+// It is safe for concurrent use, so one limiter can be shared by several
+// transfers and sessions to cap their combined rate.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  int
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a limiter allowing bytesPerSec bytes per second on average
+// and bursts of up to burst bytes. A burst <= 0 uses 32KB, the size of a copy buffer.
+func NewRateLimiter(bytesPerSec int64, burst int) *RateLimiter {
+	if burst <= 0 {
+		burst = 32 * 1024
+	}
+	return &RateLimiter{
+		rate:   float64(bytesPerSec),
+		burst:  burst,
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// SetRate changes the average rate of the limiter, e.g. outside office hours.
+func (l *RateLimiter) SetRate(bytesPerSec int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill(time.Now())
+	l.rate = float64(bytesPerSec)
+}
+
+// Burst returns the largest number of bytes allowed at once.
+func (l *RateLimiter) Burst() int {
+	return l.burst
+}
+
+// WaitN blocks until n bytes may be transferred, or returns ctx.Err() when ctx is done first.
+// A rate <= 0 does not limit at all.
+func (l *RateLimiter) WaitN(ctx context.Context, n int) error {
+	l.mu.Lock()
+	if l.rate <= 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	l.refill(now)
+	l.tokens -= float64(n)
+	wait := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// give back what was not used
+		l.mu.Lock()
+		l.tokens += float64(n)
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// refill adds the tokens earned since the last call, up to the burst size.
+func (l *RateLimiter) refill(now time.Time) {
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens += elapsed.Seconds() * l.rate
+		if l.tokens > float64(l.burst) {
+			l.tokens = float64(l.burst)
+		}
+	}
+	l.last = now
+}
+
+// SetRateLimit limits the data connections of every file transfer of the session.
+// A nil limiter removes the limit; WithRateLimit overrides it per transfer.
+func (c *Ftp) SetRateLimit(l *RateLimiter) {
+	c.limiter = l
+}
+
+// WithRateLimit limits this transfer with l instead of the session limiter.
+// A nil limiter transfers at full speed.
+func WithRateLimit(l *RateLimiter) TransferOption {
+	return func(t *transferConfig) {
+		t.limiter = l
+	}
+}
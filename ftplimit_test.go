@@ -0,0 +1,101 @@
+package ftpgo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterWait(t *testing.T) {
+	l := NewRateLimiter(100*1024, 10*1024)
+	ctx := context.Background()
+
+	// the bucket starts full
+	start := time.Now()
+	if err := l.WaitN(ctx, 10*1024); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Fatalf("first burst waited %v", elapsed)
+	}
+
+	// 40KB more at 100KB/s
+	for i := 0; i < 4; i++ {
+		if err := l.WaitN(ctx, 10*1024); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 350*time.Millisecond || elapsed > 2*time.Second {
+		t.Fatalf("50KB at 100KB/s took %v", elapsed)
+	}
+
+	// a cancelled wait gives its tokens back
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	l.SetRate(1024)
+	if err := l.WaitN(ctx, 10*1024); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitN = %v", err)
+	}
+	l.mu.Lock()
+	tokens := l.tokens
+	l.mu.Unlock()
+	if tokens < 0 {
+		t.Fatalf("tokens after a cancelled wait = %v", tokens)
+	}
+
+	// no limit
+	l.SetRate(0)
+	start = time.Now()
+	for i := 0; i < 100; i++ {
+		l.WaitN(context.Background(), 10*1024)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Fatalf("unlimited waits took %v", elapsed)
+	}
+	if NewRateLimiter(1, 0).Burst() != 32*1024 {
+		t.Fatal("default burst")
+	}
+}
+
+func TestRateLimitTransfers(t *testing.T) {
+	s := newTestServer(t)
+	data := bytes.Repeat([]byte("x"), 60*1024)
+	s.files["/f"] = data
+	c := s.dial()
+	local := filepath.Join(t.TempDir(), "f")
+
+	// 50KB after the first burst at 200KB/s
+	c.SetRateLimit(NewRateLimiter(200*1024, 10*1024))
+	start := time.Now()
+	if err := c.RetrFile("/f", local); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond || elapsed > 3*time.Second {
+		t.Fatalf("limited download took %v", elapsed)
+	}
+
+	start = time.Now()
+	if err := c.StorFile(local, "/g", WithRateLimit(NewRateLimiter(200*1024, 10*1024))); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond || elapsed > 3*time.Second {
+		t.Fatalf("limited upload took %v", elapsed)
+	}
+
+	// WithRateLimit(nil) overrides the session limiter, which would take 2.5s
+	c.SetRateLimit(NewRateLimiter(20*1024, 10*1024))
+	start = time.Now()
+	if err := c.RetrFile("/f", local, WithRateLimit(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("unlimited download took %v", elapsed)
+	}
+	if b, err := os.ReadFile(local); err != nil || !bytes.Equal(b, data) {
+		t.Fatalf("downloaded %d bytes, %v", len(b), err)
+	}
+}
@@ -0,0 +1,305 @@
+package ftpgo
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SymlinkPolicy tells a mirror what to do with symbolic links.
+type SymlinkPolicy int
+
+const (
+	// SymlinkSkip ignores symbolic links.
+	SymlinkSkip SymlinkPolicy = iota
+	// SymlinkFollow copies the file or directory a link points to.
+	SymlinkFollow
+	// SymlinkCreate recreates the link itself with the same target. MirrorDown only creates
+	// the links to relative targets within the mirrored directory, the others fail.
+	SymlinkCreate
+)
+
+// MirrorAction is what a mirror did with a file.
+type MirrorAction int
+
+const (
+	// MirrorCopied means the file was transferred.
+	MirrorCopied MirrorAction = iota
+	// MirrorSkipped means the file was already identical by size and modification time.
+	MirrorSkipped
+	// MirrorDeleted means the file was deleted because the source has no such file.
+	MirrorDeleted
+	// MirrorFailed means the file could not be transferred, see MirrorResult.Err.
+	MirrorFailed
+)
+
+// String returns the name of the action.
+func (a MirrorAction) String() string {
+	switch a {
+	case MirrorCopied:
+		return "copied"
+	case MirrorSkipped:
+		return "skipped"
+	case MirrorDeleted:
+		return "deleted"
+	case MirrorFailed:
+		return "failed"
+	}
+	return "unknown"
+}
+
+// MirrorOptions configures MirrorDown and MirrorUp. The zero value (or nil) copies
+// with as many sessions as the pool allows and skips symbolic links.
+type MirrorOptions struct {
+	// Concurrency is the number of files transferred at once; <= 0 uses the pool size.
+	Concurrency int
+	// Symlinks is the policy for symbolic links.
+	Symlinks SymlinkPolicy
+	// TransferOptions are applied to every file transfer.
+	TransferOptions []TransferOption
+	// Include limits MirrorUp to the files whose name matches one of the patterns
+	// (see FileMatch); empty includes every file.
+	Include []string
+	// Exclude skips the files and directories whose name matches one of the patterns in MirrorUp.
+	Exclude []string
+	// Delete makes MirrorUp delete the remote files and directories missing locally.
+	// Files not selected by Include and Exclude are kept.
+	Delete bool
+}
+
+// MirrorResult reports what a mirror did with one file.
+type MirrorResult struct {
+	// Path is the slash separated path of the file below the mirrored directories.
+	Path   string
+	Action MirrorAction
+	// Size is the size of the file in bytes.
+	Size int64
+	Err  error
+}
+
+// mirrorJob is a file found by the walk of a mirror.
+type mirrorJob struct {
+	rel    string
+	remote string
+	local  string
+	size   int64
+	// info is the remote file, nil when MirrorUp finds no such file
+	info *FtpFile
+}
+
+// MirrorDown downloads the remote directory tree to the local directory, creating the
+// local directories as needed. Files with the same size and modification time as the
+// remote ones are skipped, and the modification time of every copied file is set to the
+// remote one. The returned report lists every file; the error is only non-nil when the
+// mirror could not run at all, e.g. when the remote directory cannot be listed or ctx is done.
+func (p *Pool) MirrorDown(ctx context.Context, remote, local string, opts *MirrorOptions) ([]MirrorResult, error) {
+	if opts == nil {
+		opts = &MirrorOptions{}
+	}
+
+	var jobs []*mirrorJob
+	var results []MirrorResult
+	err := p.Do(ctx, func(c *Ftp) error {
+		var err error
+		jobs, results, err = c.walkRemote(ctx, remote, local, opts.Symlinks)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	jobResults := p.runMirror(ctx, jobs, opts.Concurrency, func(c *Ftp, job *mirrorJob) (MirrorAction, error) {
+		return copyAction(c.mirrorFile(ctx, job, opts.TransferOptions))
+	})
+	return append(results, jobResults...), ctx.Err()
+}
+
+// runMirror transfers the files of jobs with concurrency sessions of the pool at once.
+func (p *Pool) runMirror(ctx context.Context, jobs []*mirrorJob, concurrency int,
+	transfer func(c *Ftp, job *mirrorJob) (MirrorAction, error)) []MirrorResult {
+	if concurrency <= 0 {
+		concurrency = cap(p.slots)
+	}
+
+	results := make([]MirrorResult, len(jobs))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				job := jobs[i]
+				results[i] = MirrorResult{Path: job.rel, Size: job.size}
+				err := p.Do(ctx, func(c *Ftp) (err error) {
+					results[i].Action, err = transfer(c, job)
+					return err
+				})
+				if err != nil {
+					results[i].Action, results[i].Err = MirrorFailed, err
+				}
+			}
+		}()
+	}
+	sent := 0
+	for ; sent < len(jobs) && ctx.Err() == nil; sent++ {
+		work <- sent
+	}
+	close(work)
+	wg.Wait()
+
+	// the files left when ctx is done are not reported
+	return results[:sent]
+}
+
+// copyAction returns the action of a file transfer which may have been skipped.
+func copyAction(skipped bool, err error) (MirrorAction, error) {
+	if skipped {
+		return MirrorSkipped, err
+	}
+	return MirrorCopied, err
+}
+
+// walkRemote lists the remote tree, creates the local directories and links, and
+// returns the files to download together with the results of the links and of the
+// directories which failed.
+func (c *Ftp) walkRemote(ctx context.Context, remote, local string, symlinks SymlinkPolicy) (jobs []*mirrorJob, results []MirrorResult, err error) {
+	var walk func(rel string) error
+	walk = func(rel string) error {
+		dir := path.Join(remote, rel)
+		infos, err := c.DirContext(ctx, dir)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Join(local, filepath.FromSlash(rel)), 0755); err != nil {
+			return err
+		}
+
+		for _, info := range infos {
+			name := path.Base(info.name)
+			if name == "." || name == ".." {
+				continue
+			}
+			job := &mirrorJob{
+				rel:    path.Join(rel, name),
+				remote: path.Join(dir, name),
+				local:  filepath.Join(local, filepath.FromSlash(path.Join(rel, name))),
+				info:   info,
+			}
+
+			if info.mode&os.ModeSymlink != 0 {
+				switch symlinks {
+				case SymlinkSkip:
+					continue
+				case SymlinkCreate:
+					results = append(results, createLink(job))
+					continue
+				}
+
+				// a link to one of the directories being walked would never end
+				target := info.target
+				if !path.IsAbs(target) {
+					target = path.Join(dir, target)
+				}
+				if info.target == "" || target == "/" || target == remote || strings.HasPrefix(dir+"/", target+"/") {
+					results = append(results, MirrorResult{Path: job.rel, Action: MirrorFailed,
+						Err: errors.New("Symbolic link loop or unknown target: " + job.remote)})
+					continue
+				}
+				// SIZE only succeeds for regular files
+				if size, err := c.Size(job.remote); err == nil {
+					job.info = &FtpFile{name: name, size: int64(size)}
+				} else {
+					job.info = &FtpFile{name: name, mode: os.ModeDir}
+				}
+			}
+
+			if !job.info.IsDir() {
+				job.size = job.info.size
+				jobs = append(jobs, job)
+				continue
+			}
+			if err := walk(job.rel); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				results = append(results, MirrorResult{Path: job.rel, Action: MirrorFailed, Err: err})
+			}
+		}
+		return nil
+	}
+
+	return jobs, results, walk("")
+}
+
+// mirrorFile downloads the file of job unless the local file is identical.
+func (c *Ftp) mirrorFile(ctx context.Context, job *mirrorJob, opts []TransferOption) (skipped bool, err error) {
+	mtime, err := c.modTime(job.remote, job.info)
+	if err != nil {
+		return false, err
+	}
+
+	if local, err := os.Stat(job.local); err == nil && local.Mode().IsRegular() &&
+		local.Size() == job.size && !mtime.IsZero() && local.ModTime().Truncate(time.Second).Equal(mtime.Truncate(time.Second)) {
+		return true, nil
+	}
+
+	opts = append(opts[:len(opts):len(opts)], WithTotal(job.size))
+	if err := c.RetrFileContext(ctx, job.remote, job.local, opts...); err != nil {
+		return false, err
+	}
+	if !mtime.IsZero() {
+		return false, os.Chtimes(job.local, mtime, mtime)
+	}
+	return false, nil
+}
+
+// modTime returns the modification time of the remote file to the second, from the
+// MLSD modify fact or with MDTM, since LIST only gives the time to the minute or day.
+// The zero time is returned when the server cannot tell.
+func (c *Ftp) modTime(remote string, info *FtpFile) (time.Time, error) {
+	if info.Fact("modify") != "" {
+		return info.mtime, nil
+	}
+
+	mtime, err := c.Mdtm(remote)
+	if err != nil {
+		if isUnsupported(err) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+	return mtime, nil
+}
+
+// createLink recreates a remote symbolic link locally.
+func createLink(job *mirrorJob) MirrorResult {
+	result := MirrorResult{Path: job.rel, Action: MirrorCopied}
+	if job.info.target == "" {
+		result.Action, result.Err = MirrorFailed, errors.New("Unknown target of symbolic link: "+job.remote)
+		return result
+	}
+	// the target is chosen by the server, it must not reach out of the local directory
+	if target := path.Join(path.Dir(job.rel), job.info.target); path.IsAbs(job.info.target) ||
+		target == ".." || strings.HasPrefix(target, "../") {
+		result.Action, result.Err = MirrorFailed, errors.New("Symbolic link target outside the mirror: "+job.remote)
+		return result
+	}
+
+	if target, err := os.Readlink(job.local); err == nil {
+		if target == filepath.FromSlash(job.info.target) {
+			result.Action = MirrorSkipped
+			return result
+		}
+		os.Remove(job.local)
+	}
+	if err := os.Symlink(filepath.FromSlash(job.info.target), job.local); err != nil {
+		result.Action, result.Err = MirrorFailed, err
+	}
+	return result
+}
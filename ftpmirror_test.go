@@ -0,0 +1,130 @@
+package ftpgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// mirrorActions maps the paths of a mirror report to their action.
+func mirrorActions(t *testing.T, results []MirrorResult) map[string]MirrorAction {
+	actions := map[string]MirrorAction{}
+	for _, r := range results {
+		if r.Path == "" {
+			t.Fatalf("result without a path: %+v", r)
+		}
+		actions[r.Path] = r.Action
+	}
+	return actions
+}
+
+func TestMirrorDown(t *testing.T) {
+	s := newTestServer(t, "MLST type*;size*;modify*;")
+	s.dirs["/pub"] = true
+	s.dirs["/pub/sub"] = true
+	s.files["/pub/a.txt"] = []byte("aaa")
+	s.files["/pub/sub/b.txt"] = []byte("bb")
+	p, _ := newTestPool(s, 2)
+	local := t.TempDir()
+
+	results, err := p.MirrorDown(context.Background(), "/pub", local, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions := mirrorActions(t, results)
+	if len(actions) != 2 || actions["a.txt"] != MirrorCopied || actions["sub/b.txt"] != MirrorCopied {
+		t.Fatalf("first mirror = %v", actions)
+	}
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for name, data := range map[string]string{"a.txt": "aaa", "sub/b.txt": "bb"} {
+		file := filepath.Join(local, filepath.FromSlash(name))
+		got, err := os.ReadFile(file)
+		if err != nil || string(got) != data {
+			t.Fatalf("%s = %q, %v", name, got, err)
+		}
+		if info, err := os.Stat(file); err != nil || !info.ModTime().Equal(mtime) {
+			t.Fatalf("%s modified at %v, %v", name, info.ModTime(), err)
+		}
+	}
+
+	// nothing changed since
+	results, err = p.MirrorDown(context.Background(), "/pub", local, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions = mirrorActions(t, results)
+	if len(actions) != 2 || actions["a.txt"] != MirrorSkipped || actions["sub/b.txt"] != MirrorSkipped {
+		t.Fatalf("second mirror = %v", actions)
+	}
+}
+
+func TestMirrorDownSymlinks(t *testing.T) {
+	s := newTestServer(t)
+	s.files["/pub/a.txt"] = []byte("aaa")
+	s.lists["/pub"] = "-rw-r--r--  1 ftp ftp    3 Jan 02  2020 a.txt\r\n" +
+		"lrwxrwxrwx  1 ftp ftp    5 Jan 02  2020 link -> a.txt\r\n" +
+		"lrwxrwxrwx  1 ftp ftp   11 Jan 02  2020 abs -> /etc/passwd\r\n" +
+		"lrwxrwxrwx  1 ftp ftp   10 Jan 02  2020 up -> ../outside\r\n" +
+		"lrwxrwxrwx  1 ftp ftp   12 Jan 02  2020 down -> sub/../../x\r\n"
+	p, _ := newTestPool(s, 1)
+	local := t.TempDir()
+
+	results, err := p.MirrorDown(context.Background(), "/pub", local, &MirrorOptions{Symlinks: SymlinkCreate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions := mirrorActions(t, results)
+	want := map[string]MirrorAction{"a.txt": MirrorCopied, "link": MirrorCopied,
+		"abs": MirrorFailed, "up": MirrorFailed, "down": MirrorFailed}
+	if fmt.Sprint(actions) != fmt.Sprint(want) {
+		t.Fatalf("mirror = %v, want %v", actions, want)
+	}
+	if target, err := os.Readlink(filepath.Join(local, "link")); err != nil || target != "a.txt" {
+		t.Fatalf("link -> %q, %v", target, err)
+	}
+	for _, name := range []string{"abs", "up", "down"} {
+		if _, err := os.Lstat(filepath.Join(local, name)); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("%s created: %v", name, err)
+		}
+	}
+
+	// the links are kept by a second mirror
+	results, err = p.MirrorDown(context.Background(), "/pub", local, &MirrorOptions{Symlinks: SymlinkCreate})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actions := mirrorActions(t, results); actions["link"] != MirrorSkipped {
+		t.Fatalf("second mirror = %v", actions)
+	}
+}
+
+func TestMirrorDownCancel(t *testing.T) {
+	s := newTestServer(t)
+	s.dirs["/pub"] = true
+	for i := 0; i < 5; i++ {
+		s.files[fmt.Sprintf("/pub/f%d", i)] = []byte("data")
+	}
+	p, _ := newTestPool(s, 1)
+	local := t.TempDir()
+
+	// cancelled during the first transfer
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	opts := &MirrorOptions{TransferOptions: []TransferOption{WithProgress(func(Progress) { cancel() })}}
+	results, err := p.MirrorDown(ctx, "/pub", local, opts)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("MirrorDown = %v", err)
+	}
+	if len(results) == 0 || len(results) >= 5 {
+		t.Fatalf("%d files reported", len(results))
+	}
+	for path, action := range mirrorActions(t, results) {
+		if _, err := os.Stat(filepath.Join(local, path)); action == MirrorCopied && err != nil {
+			t.Fatalf("%s reported copied: %v", path, err)
+		}
+	}
+}
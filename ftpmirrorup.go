@@ -0,0 +1,194 @@
+package ftpgo
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MirrorUp uploads the local directory tree to the remote directory, creating the remote
+// directories with MKD as needed. Only the files selected by opts.Include and opts.Exclude
+// are uploaded, files with the same size and modification time as the remote ones are
+// skipped, and the modification time of every uploaded file is set when the server allows it.
+// With opts.Delete the remote files and directories missing locally are deleted.
+// Symbolic links are followed with SymlinkFollow; FTP cannot create them, so SymlinkCreate
+// reports them as failed.
+// The returned report lists every file; the error is only non-nil when the mirror could
+// not run at all, e.g. when the local directory cannot be read or ctx is done.
+func (p *Pool) MirrorUp(ctx context.Context, local, remote string, opts *MirrorOptions) ([]MirrorResult, error) {
+	if opts == nil {
+		opts = &MirrorOptions{}
+	}
+
+	var jobs []*mirrorJob
+	var results []MirrorResult
+	err := p.Do(ctx, func(c *Ftp) error {
+		var err error
+		jobs, results, err = c.walkLocal(ctx, local, remote, opts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	jobResults := p.runMirror(ctx, jobs, opts.Concurrency, func(c *Ftp, job *mirrorJob) (MirrorAction, error) {
+		return copyAction(c.mirrorUpFile(ctx, job, opts.TransferOptions))
+	})
+	return append(results, jobResults...), ctx.Err()
+}
+
+// walkLocal reads the local tree, creates the missing remote directories, deletes what
+// is missing locally when asked to, and returns the files to upload together with the
+// results of the deletions, links and directories which failed.
+func (c *Ftp) walkLocal(ctx context.Context, local, remote string, opts *MirrorOptions) (jobs []*mirrorJob, results []MirrorResult, err error) {
+	root, name := splitRemoteRoot(remote)
+	if err := NewFtpFS(c, root).MkdirAll(name, 0755); err != nil {
+		return nil, nil, err
+	}
+
+	fail := func(rel string, err error) {
+		results = append(results, MirrorResult{Path: rel, Action: MirrorFailed, Err: err})
+	}
+
+	var walk func(rel string) error
+	walk = func(rel string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		dir := path.Join(remote, rel)
+		entries, err := os.ReadDir(filepath.Join(local, filepath.FromSlash(rel)))
+		if err != nil {
+			return err
+		}
+		infos, err := c.DirContext(ctx, dir)
+		if err != nil {
+			return err
+		}
+		remoteFiles := make(map[string]*FtpFile, len(infos))
+		for _, info := range infos {
+			remoteFiles[path.Base(info.name)] = info
+		}
+
+		seen := make(map[string]bool, len(entries))
+		for _, entry := range entries {
+			name := entry.Name()
+			if FileMatch(name, opts.Exclude...) {
+				continue
+			}
+			seen[name] = true
+
+			job := &mirrorJob{
+				rel:    path.Join(rel, name),
+				remote: path.Join(dir, name),
+				local:  filepath.Join(local, filepath.FromSlash(path.Join(rel, name))),
+				info:   remoteFiles[name],
+			}
+			info, err := entry.Info()
+			if err == nil && info.Mode()&os.ModeSymlink != 0 {
+				switch opts.Symlinks {
+				case SymlinkSkip:
+					continue
+				case SymlinkCreate:
+					fail(job.rel, errors.New("Symbolic links cannot be created on the server: "+job.local))
+					continue
+				}
+				// os.Stat follows the link
+				info, err = os.Stat(job.local)
+			}
+			if err != nil {
+				fail(job.rel, err)
+				continue
+			}
+
+			if !info.IsDir() {
+				if len(opts.Include) == 0 || FileMatch(name, opts.Include...) {
+					job.size = info.Size()
+					jobs = append(jobs, job)
+				}
+				continue
+			}
+
+			if job.info == nil || !job.info.IsDir() {
+				if _, err := c.Mkd(job.remote); err != nil {
+					fail(job.rel, err)
+					continue
+				}
+			}
+			if err := walk(job.rel); err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				fail(job.rel, err)
+			}
+		}
+
+		if opts.Delete {
+			for name, info := range remoteFiles {
+				if seen[name] || name == "." || name == ".." || FileMatch(name, opts.Exclude...) {
+					continue
+				}
+				if !info.IsDir() && len(opts.Include) > 0 && !FileMatch(name, opts.Include...) {
+					continue
+				}
+
+				result := MirrorResult{Path: path.Join(rel, name), Action: MirrorDeleted, Size: info.size}
+				if info.IsDir() {
+					err = NewFtpFS(c, dir).RemoveAll(name)
+				} else {
+					err = c.Delete(path.Join(dir, name))
+				}
+				if err != nil {
+					result.Action, result.Err = MirrorFailed, err
+				}
+				results = append(results, result)
+			}
+		}
+		return nil
+	}
+
+	return jobs, results, walk("")
+}
+
+// mirrorUpFile uploads the file of job unless the remote file is identical.
+func (c *Ftp) mirrorUpFile(ctx context.Context, job *mirrorJob, opts []TransferOption) (skipped bool, err error) {
+	local, err := os.Stat(job.local)
+	if err != nil {
+		return false, err
+	}
+	mtime := local.ModTime().Truncate(time.Second)
+
+	if job.info != nil && !job.info.IsDir() && job.info.size == local.Size() {
+		remote, err := c.modTime(job.remote, job.info)
+		if err != nil {
+			return false, err
+		}
+		if remote.Truncate(time.Second).Equal(mtime) {
+			return true, nil
+		}
+	}
+
+	if err := c.StorFileContext(ctx, job.local, job.remote, opts...); err != nil {
+		return false, err
+	}
+	if err := c.SetModTime(job.remote, mtime); err != nil && !isUnsupported(err) {
+		return false, err
+	}
+	return false, nil
+}
+
+// splitRemoteRoot splits a remote path into the root of a FtpFS and a fs.FS path in it.
+func splitRemoteRoot(remote string) (root, name string) {
+	if path.IsAbs(remote) {
+		root = "/"
+	}
+	name = strings.TrimPrefix(path.Clean(remote), "/")
+	if name == "" {
+		name = "."
+	}
+	return root, name
+}
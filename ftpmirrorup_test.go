@@ -0,0 +1,70 @@
+package ftpgo
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMirrorUp(t *testing.T) {
+	s := newTestServer(t, "MLST type*;size*;modify*;")
+	s.dirs["/pub"] = true
+	s.dirs["/pub/olddir"] = true
+	s.files["/pub/old.txt"] = []byte("old")
+	s.files["/pub/olddir/x.txt"] = []byte("x")
+	p, _ := newTestPool(s, 2)
+
+	local := t.TempDir()
+	os.Mkdir(filepath.Join(local, "sub"), 0755)
+	for name, data := range map[string]string{"a.txt": "aaa", "sub/b.txt": "bb", "skip.log": "log"} {
+		if err := os.WriteFile(filepath.Join(local, filepath.FromSlash(name)), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("a.txt", filepath.Join(local, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &MirrorOptions{Exclude: []string{"*.log"}, Delete: true, Symlinks: SymlinkCreate}
+	results, err := p.MirrorUp(context.Background(), local, "/pub", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions := mirrorActions(t, results)
+	want := map[string]MirrorAction{"a.txt": MirrorCopied, "sub/b.txt": MirrorCopied, "link": MirrorFailed,
+		"old.txt": MirrorDeleted, "olddir": MirrorDeleted}
+	if fmt.Sprint(actions) != fmt.Sprint(want) {
+		t.Fatalf("mirror = %v, want %v", actions, want)
+	}
+	s.mu.Lock()
+	files := fmt.Sprint(s.files)
+	dirs := fmt.Sprint(s.dirs)
+	s.mu.Unlock()
+	if files != "map[/pub/a.txt:[97 97 97] /pub/sub/b.txt:[98 98]]" || dirs != "map[/pub:true /pub/sub:true]" {
+		t.Fatalf("server has %s %s", files, dirs)
+	}
+
+	// the server lists every file as modified at this time
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, name := range []string{"a.txt", "sub/b.txt"} {
+		if err := os.Chtimes(filepath.Join(local, filepath.FromSlash(name)), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts.Symlinks = SymlinkFollow
+	results, err = p.MirrorUp(context.Background(), local, "/pub", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actions = mirrorActions(t, results)
+	want = map[string]MirrorAction{"a.txt": MirrorSkipped, "sub/b.txt": MirrorSkipped, "link": MirrorCopied}
+	if fmt.Sprint(actions) != fmt.Sprint(want) {
+		t.Fatalf("second mirror = %v, want %v", actions, want)
+	}
+	if data, _ := s.file("/pub/link"); data != "aaa" {
+		t.Fatalf("followed link uploaded %q", data)
+	}
+}
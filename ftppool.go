@@ -0,0 +1,166 @@
+package ftpgo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrPoolClosed is returned by Acquire after the pool was closed.
+var ErrPoolClosed = errors.New("Pool closed")
+
+// Pool manages a bounded set of logged-in sessions to one server for concurrent use.
+// A *Ftp is not safe for concurrent use, so every goroutine acquires its own session
+// and releases it when done. At most max sessions are open at once, which keeps
+// the workers below the per-user connection limit of the server.
+type Pool struct {
+	dial        func(ctx context.Context) (*Ftp, error)
+	slots       chan struct{}
+	idle        chan idleSession
+	mu          sync.Mutex
+	closed      bool
+	healthCheck time.Duration
+}
+
+// idleSession is a released session waiting in the pool.
+type idleSession struct {
+	c     *Ftp
+	since time.Time
+}
+
+// NewPool creates a pool of at most max sessions. dial opens a new logged-in session,
+// e.g. with FtpConnectContext and LoginContext; sessions are only dialed when needed.
+func NewPool(max int, dial func(ctx context.Context) (*Ftp, error)) *Pool {
+	if max <= 0 {
+		max = 1
+	}
+	return &Pool{
+		dial:  dial,
+		slots: make(chan struct{}, max),
+		idle:  make(chan idleSession, max),
+	}
+}
+
+// SetHealthCheck sets how long a session may be idle before Acquire checks it with NOOP.
+// The default 0 checks every idle session before handing it out.
+func (p *Pool) SetHealthCheck(d time.Duration) {
+	p.healthCheck = d
+}
+
+// Acquire returns an idle session, or dials a new one while fewer than max are open,
+// otherwise it waits for a session to be released or for ctx to be done.
+// Idle sessions failing the NOOP health check are closed and replaced.
+func (p *Pool) Acquire(ctx context.Context) (*Ftp, error) {
+	for {
+		p.mu.Lock()
+		closed := p.closed
+		p.mu.Unlock()
+		if closed {
+			return nil, ErrPoolClosed
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		// reuse idle sessions before opening new ones
+		select {
+		case s := <-p.idle:
+			if p.alive(ctx, s) {
+				return s.c, nil
+			}
+			continue
+		default:
+		}
+
+		select {
+		case s := <-p.idle:
+			if p.alive(ctx, s) {
+				return s.c, nil
+			}
+		case p.slots <- struct{}{}:
+			c, err := p.dial(ctx)
+			if err != nil {
+				<-p.slots
+				return nil, err
+			}
+			return c, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// alive checks an idle session and evicts it when the connection is dead.
+// The NOOP is bounded by ctx and by the timeout of the session, so a server
+// which stopped answering cannot block Acquire.
+func (p *Pool) alive(ctx context.Context, s idleSession) bool {
+	if time.Since(s.since) < p.healthCheck && !s.c.broken {
+		return true
+	}
+	if s.c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.c.timeout)
+		defer cancel()
+	}
+	if _, _, err := s.c.SendCmdContext(ctx, 200, "NOOP"); err != nil {
+		p.Discard(s.c)
+		return false
+	}
+	return true
+}
+
+// Release returns a session acquired from the pool. A session which lost its
+// control connection is closed instead of being kept.
+func (p *Pool) Release(c *Ftp) {
+	if c.broken {
+		p.Discard(c)
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		c.Quit()
+		<-p.slots
+		return
+	}
+	// never blocks: there are no more sessions than slots
+	p.idle <- idleSession{c: c, since: time.Now()}
+}
+
+// Discard closes a session acquired from the pool instead of releasing it,
+// e.g. after an error which left it in an unknown state.
+// No QUIT is sent, the server may never answer it.
+func (p *Pool) Discard(c *Ftp) {
+	c.textprotoConn.Close()
+	<-p.slots
+}
+
+// Do runs fn with a session of the pool and releases it afterwards.
+func (p *Pool) Do(ctx context.Context, fn func(c *Ftp) error) error {
+	c, err := p.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer p.Release(c)
+
+	return fn(c)
+}
+
+// Close quits the idle sessions. Sessions in use are closed when they are released.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+
+	for {
+		select {
+		case s := <-p.idle:
+			s.c.Quit()
+			<-p.slots
+		default:
+			return nil
+		}
+	}
+}
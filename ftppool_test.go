@@ -0,0 +1,158 @@
+package ftpgo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestPool returns a pool of at most max sessions to the server, with a short
+// timeout, and the number of sessions dialed.
+func newTestPool(s *testServer, max int) (*Pool, func() int) {
+	var mu sync.Mutex
+	dials := 0
+	p := NewPool(max, func(ctx context.Context) (*Ftp, error) {
+		mu.Lock()
+		dials++
+		mu.Unlock()
+		c, err := FtpConnectContext(ctx, s.ln.Addr().String(), 200*time.Millisecond)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.LoginContext(ctx, "user", "pass"); err != nil {
+			c.Quit()
+			return nil, err
+		}
+		c.SetPasv(true)
+		return c, nil
+	})
+	s.t.Cleanup(func() { p.Close() })
+	return p, func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return dials
+	}
+}
+
+func TestPoolDeadSession(t *testing.T) {
+	s := newTestServer(t)
+	p, dials := newTestPool(s, 1)
+	ctx := context.Background()
+
+	c1, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Release(c1)
+	if c, err := p.Acquire(ctx); err != nil || c != c1 || dials() != 1 {
+		t.Fatalf("Acquire(idle) = %p, %v, %d dials", c, err, dials())
+	}
+
+	// the connection was lost while idle
+	c1.conn.Close()
+	p.Release(c1)
+	c2, err := p.Acquire(ctx)
+	if err != nil || c2 == c1 || dials() != 2 {
+		t.Fatalf("Acquire(closed) = %p, %v, %d dials", c2, err, dials())
+	}
+
+	// the server stopped answering while idle
+	p.Release(c2)
+	s.mu.Lock()
+	s.replies["NOOP"] = ""
+	s.mu.Unlock()
+	start := time.Now()
+	c3, err := p.Acquire(ctx)
+	if err != nil || c3 == c2 || dials() != 3 {
+		t.Fatalf("Acquire(unanswered) = %p, %v, %d dials", c3, err, dials())
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Acquire(unanswered) returned after %v", elapsed)
+	}
+	if s.sent("QUIT") {
+		t.Fatal("QUIT sent to a dead session")
+	}
+	p.Release(c3)
+}
+
+func TestPoolLimit(t *testing.T) {
+	s := newTestServer(t)
+	p, dials := newTestPool(s, 2)
+	ctx := context.Background()
+
+	c1, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a third session waits for ctx
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := p.Acquire(timeout); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Acquire over the limit = %v", err)
+	}
+
+	// or for a session to be released
+	acquired := make(chan *Ftp)
+	go func() {
+		c, err := p.Acquire(ctx)
+		if err != nil {
+			t.Error(err)
+		}
+		acquired <- c
+	}()
+	time.Sleep(20 * time.Millisecond)
+	p.Release(c1)
+	if c := <-acquired; c != c1 {
+		t.Fatalf("waiting Acquire = %p, want the released %p", c, c1)
+	}
+	if dials() != 2 {
+		t.Fatalf("%d sessions dialed, want 2", dials())
+	}
+	p.Release(c1)
+	p.Release(c2)
+}
+
+func TestPoolCancelAcquire(t *testing.T) {
+	s := newTestServer(t)
+	p, _ := newTestPool(s, 1)
+
+	c, err := p.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := p.Acquire(ctx)
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Acquire = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Acquire not cancelled")
+	}
+
+	// the slot of the cancelled Acquire is not lost
+	p.Release(c)
+	if c2, err := p.Acquire(context.Background()); err != nil || c2 != c {
+		t.Fatalf("Acquire after cancel = %p, %v", c2, err)
+	}
+	p.Release(c)
+
+	p.Close()
+	if _, err := p.Acquire(context.Background()); err != ErrPoolClosed {
+		t.Fatalf("Acquire after Close = %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package ftpgo
+
+import (
+	"time"
+)
+
+// Progress is the state of a file transfer passed to a ProgressFunc.
+type Progress struct {
+	// Transferred is the number of bytes transferred so far, including a resumed offset.
+	Transferred int64
+	// Total is the size of the file, or -1 when it is not known.
+	Total int64
+	// Elapsed is the time since the data connection was opened.
+	Elapsed time.Duration
+	// Rate is the current transfer rate in bytes per second.
+	Rate float64
+}
+
+// ProgressFunc is called as a file transfer makes progress.
+type ProgressFunc func(p Progress)
+
+// rateInterval is the period over which the current transfer rate is measured.
+const rateInterval = time.Second
+
+// SetProgress sets a hook called during every file transfer of the session.
+// A nil fn disables progress reporting; WithProgress overrides it per transfer.
+func (c *Ftp) SetProgress(fn ProgressFunc) {
+	c.progress = fn
+}
+
+// WithProgress reports the progress of this transfer to fn instead of the session hook.
+func WithProgress(fn ProgressFunc) TransferOption {
+	return func(t *transferConfig) {
+		t.progress = fn
+	}
+}
+
+// WithTotal sets the size reported as Progress.Total, which saves the SIZE command
+// RetrRequest otherwise issues when progress is reported.
+func WithTotal(size int64) TransferOption {
+	return func(t *transferConfig) {
+		t.total = size
+	}
+}
+
+// progressTracker
+type progressTracker struct {
+	fn          ProgressFunc
+	start       time.Time
+	transferred int64
+	total       int64
+	rate        float64
+	sampleTime  time.Time
+	sampleBytes int64
+}
+
+// newProgressTracker
+func newProgressTracker(fn ProgressFunc, offset, total int64) *progressTracker {
+	now := time.Now()
+	return &progressTracker{
+		fn:          fn,
+		start:       now,
+		transferred: offset,
+		total:       total,
+		sampleTime:  now,
+		sampleBytes: offset,
+	}
+}
+
+// add counts n transferred bytes and reports the progress.
+func (p *progressTracker) add(n int) {
+	p.transferred += int64(n)
+
+	now := time.Now()
+	elapsed := now.Sub(p.sampleTime)
+	switch {
+	case elapsed >= rateInterval:
+		p.rate = float64(p.transferred-p.sampleBytes) / elapsed.Seconds()
+		p.sampleTime, p.sampleBytes = now, p.transferred
+	case p.sampleTime.Equal(p.start) && elapsed > 0:
+		// average until the first interval is complete
+		p.rate = float64(p.transferred-p.sampleBytes) / elapsed.Seconds()
+	}
+
+	p.fn(Progress{
+		Transferred: p.transferred,
+		Total:       p.total,
+		Elapsed:     now.Sub(p.start),
+		Rate:        p.rate,
+	})
+}
@@ -0,0 +1,106 @@
+package ftpgo
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// recordProgress returns a ProgressFunc appending to *reports.
+func recordProgress(reports *[]Progress) ProgressFunc {
+	return func(p Progress) {
+		*reports = append(*reports, p)
+	}
+}
+
+// checkProgress checks that the reports grow up to want bytes of total.
+func checkProgress(t *testing.T, reports []Progress, want, total int64) {
+	t.Helper()
+	if len(reports) == 0 {
+		t.Fatal("no progress reported")
+	}
+	for i, p := range reports {
+		if p.Total != total || p.Rate < 0 || (i > 0 && (p.Transferred < reports[i-1].Transferred || p.Elapsed < reports[i-1].Elapsed)) {
+			t.Fatalf("report %d = %+v", i, p)
+		}
+	}
+	if last := reports[len(reports)-1]; last.Transferred != want {
+		t.Fatalf("last report = %+v, want %d bytes", last, want)
+	}
+}
+
+func TestProgressRetr(t *testing.T) {
+	s := newTestServer(t)
+	data := bytes.Repeat([]byte("0123456789"), 10000)
+	s.files["/f"] = data
+	c := s.dial()
+	local := filepath.Join(t.TempDir(), "f")
+
+	var reports []Progress
+	if err := c.RetrFile("/f", local, WithProgress(recordProgress(&reports))); err != nil {
+		t.Fatal(err)
+	}
+	// the total comes from SIZE
+	checkProgress(t, reports, int64(len(data)), int64(len(data)))
+	if !s.sent("SIZE /f") {
+		t.Fatal("SIZE not sent")
+	}
+
+	// WithTotal saves the SIZE command, the offset counts as transferred
+	s.mu.Lock()
+	s.cmds = nil
+	s.mu.Unlock()
+	reports = nil
+	r, err := c.RetrRequestAt("/f", 1000, WithProgress(recordProgress(&reports)), WithTotal(int64(len(data))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, r)
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkProgress(t, reports, int64(len(data)), int64(len(data)))
+	if first := reports[0].Transferred; first <= 1000 {
+		t.Fatalf("first report after the offset = %d", first)
+	}
+	if s.sent("SIZE /f") {
+		t.Fatal("SIZE sent with WithTotal")
+	}
+
+	// the session hook, without SIZE the total is unknown
+	s.mu.Lock()
+	s.replies["SIZE"] = "502 not implemented"
+	s.mu.Unlock()
+	reports = nil
+	c.SetProgress(recordProgress(&reports))
+	if err := c.RetrFile("/f", local); err != nil {
+		t.Fatal(err)
+	}
+	checkProgress(t, reports, int64(len(data)), -1)
+
+	// no reports once disabled
+	c.SetProgress(nil)
+	reports = nil
+	if err := c.RetrFile("/f", local); err != nil || len(reports) != 0 {
+		t.Fatalf("RetrFile = %v, %d reports", err, len(reports))
+	}
+}
+
+func TestProgressStor(t *testing.T) {
+	s := newTestServer(t)
+	c := s.dial()
+	local := filepath.Join(t.TempDir(), "f")
+	data := bytes.Repeat([]byte("x"), 100000)
+	if err := os.WriteFile(local, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var reports []Progress
+	if err := c.StorFile(local, "/f", WithProgress(recordProgress(&reports))); err != nil {
+		t.Fatal(err)
+	}
+	// the total is the size of the local file
+	checkProgress(t, reports, int64(len(data)), int64(len(data)))
+}
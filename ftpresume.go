@@ -0,0 +1,198 @@
+package ftpgo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// RetrFileResume fetches the specified file like RetrFile, but continues a partial local file
+// with REST instead of downloading it again. The final local size is checked against SIZE.
+func (c *Ftp) RetrFileResume(remote, local string, opts ...TransferOption) error {
+	return c.RetrFileResumeContext(context.Background(), remote, local, opts...)
+}
+
+// RetrFileResumeContext fetches the specified file like RetrFileResume, giving up when ctx is done.
+func (c *Ftp) RetrFileResumeContext(ctx context.Context, remote, local string, opts ...TransferOption) error {
+	return c.retryContext(ctx, func() error {
+		return c.retrFileResume(ctx, remote, local, opts)
+	})
+}
+
+// retrFileResume
+func (c *Ftp) retrFileResume(ctx context.Context, remote, local string, opts []TransferOption) error {
+	if c.asciiTransfer(c.newTransfer(opts), remote) {
+		return errASCIIResume
+	}
+
+	size, err := c.size(ctx, remote)
+	if err != nil {
+		return err
+	}
+	total := int64(size)
+
+	var offset int64
+	if info, err := os.Stat(local); err == nil && info.Mode().IsRegular() {
+		offset = info.Size()
+	}
+	if offset > total {
+		// the local file is not a prefix of the remote one
+		offset = 0
+	}
+
+	file, err := os.OpenFile(local, os.O_WRONLY|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+
+	t := c.newTransfer(opts)
+	t.total = total
+	err = c.retrAt(ctx, t, remote, file, offset)
+	if err != nil && offset > 0 && isUnsupported(err) {
+		// no REST support, start over
+		err = c.retrAt(ctx, t, remote, file, 0)
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := verifyLocalSize(local, total); err != nil {
+		return err
+	}
+	return c.verifyFileHash(c.newTransfer(opts), local, remote)
+}
+
+// retrAt downloads remote into file from offset, truncating what follows.
+func (c *Ftp) retrAt(ctx context.Context, t *transferConfig, remote string, file *os.File, offset int64) error {
+	if err := file.Truncate(offset); err != nil {
+		return err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	if offset == t.total {
+		return nil
+	}
+
+	t.offset, t.start = uint64(offset), offset
+	reader, err := c.transferRequest(ctx, t, remote, "RETR %s", remote)
+	if err != nil {
+		return err
+	}
+
+	err = copyData(file, reader)
+	if cerr := reader.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// StorFileResume stores the specified file like StorFile, but continues a partial remote file
+// instead of uploading it again. REST+STOR is used when the server advertises REST STREAM,
+// APPE otherwise. The final remote size is checked against the local file.
+// With WithTempName the temporary file is continued, and kept when the upload fails.
+func (c *Ftp) StorFileResume(local, remote string, opts ...TransferOption) error {
+	return c.StorFileResumeContext(context.Background(), local, remote, opts...)
+}
+
+// StorFileResumeContext stores the specified file like StorFileResume, giving up when ctx is done.
+func (c *Ftp) StorFileResumeContext(ctx context.Context, local, remote string, opts ...TransferOption) error {
+	file, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	total := info.Size()
+
+	t := c.newTransfer(opts)
+	if c.asciiTransfer(t, remote) {
+		return errASCIIResume
+	}
+	t.total = total
+	name := t.tempPath(remote)
+
+	var offset int64
+	size, err := c.size(ctx, name)
+	switch {
+	case err == nil:
+		offset = int64(size)
+	case !hasCode(err, StatusFileActionIgnored, StatusFileUnavailable, StatusBadArguments):
+		// servers answer 450, 550 or 501 when there is no remote file yet
+		return err
+	}
+	if offset > total {
+		// the remote file is not a prefix of the local one
+		offset = 0
+	}
+	if offset == total {
+		if err := c.verifyFileHash(t, local, name); err != nil {
+			return err
+		}
+		return c.commitTemp(name, remote, total)
+	}
+
+	if _, err = file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	var writer io.WriteCloser
+	switch {
+	case offset == 0:
+		writer, err = c.transferRequest(ctx, t, name, "STOR %s", name)
+	case c.Features().HasParam("REST", "STREAM"):
+		t.offset, t.start = uint64(offset), offset
+		writer, err = c.transferRequest(ctx, t, name, "STOR %s", name)
+	default:
+		t.start = offset
+		writer, err = c.transferRequest(ctx, t, name, "APPE %s", name)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = copyData(writer, file)
+	if cerr := writer.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := c.verifyFileHash(t, local, name); err != nil {
+		return err
+	}
+	return c.commitTemp(name, remote, total)
+}
+
+// verifyLocalSize
+func verifyLocalSize(local string, size int64) error {
+	info, err := os.Stat(local)
+	if err != nil {
+		return err
+	}
+	if info.Size() != size {
+		return fmt.Errorf("Size mismatch for %s: %d bytes, expected %d", local, info.Size(), size)
+	}
+	return nil
+}
+
+// verifyRemoteSize
+func (c *Ftp) verifyRemoteSize(remote string, size int64) error {
+	remoteSize, err := c.size(context.Background(), remote)
+	if err != nil {
+		return err
+	}
+	if int64(remoteSize) != size {
+		return fmt.Errorf("Size mismatch for %s: %d bytes, expected %d", remote, remoteSize, size)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package ftpgo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStorFileResumeNoRemoteFile(t *testing.T) {
+	local := filepath.Join(t.TempDir(), "f")
+	if err := os.WriteFile(local, []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, reply := range []string{"550 /f: No such file", "450 /f: No such file", "501 /f: Not a plain file"} {
+		s := newTestServer(t)
+		s.once["SIZE"] = reply
+		c := s.dial()
+
+		if err := c.StorFileResume(local, "/f"); err != nil {
+			t.Fatalf("SIZE %q: %v", reply, err)
+		}
+		s.mu.Lock()
+		data := string(s.files["/f"])
+		s.mu.Unlock()
+		if !s.sent("STOR /f") || data != "0123456789" {
+			t.Fatalf("SIZE %q: %q", reply, data)
+		}
+	}
+}
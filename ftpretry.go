@@ -0,0 +1,206 @@
+package ftpgo
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy makes a session recover from a lost control connection (a 421 reply
+// or a broken connection). The session reconnects, logs in again with the stored
+// credentials and restores TLS, TYPE and the working directory. Idempotent operations
+// (listings, SIZE, MDTM, MLST and downloads, which resume with REST) are then retried;
+// other operations return their error and the next call starts on the new connection.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first failure.
+	MaxRetries int
+	// InitialBackoff is the wait before the first reconnect.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the wait between reconnects.
+	MaxBackoff time.Duration
+	// Multiplier grows the wait after every attempt; values below 1 mean 2.
+	Multiplier float64
+	// Jitter randomizes every wait by up to this fraction (0.0 - 1.0).
+	Jitter float64
+}
+
+// DefaultRetryPolicy returns a policy retrying 5 times, waiting from 1s up to 30s.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxRetries:     5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// backoff returns the wait before the given (0 based) attempt.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+
+	wait := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt))
+	if p.MaxBackoff > 0 && wait > float64(p.MaxBackoff) {
+		wait = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		wait *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(wait)
+}
+
+// SetRetryPolicy enables automatic reconnects and retries for the session. nil disables them.
+// The working directory is tracked with PWD after every Cwd/Cdup while a policy is set.
+func (c *Ftp) SetRetryPolicy(p *RetryPolicy) {
+	c.retry = p
+	if p != nil {
+		c.rememberCwd()
+	}
+}
+
+// Reconnect replaces the control connection with a new one and restores the session:
+// AUTH TLS, login, TYPE and the working directory.
+func (c *Ftp) Reconnect() error {
+	return c.ReconnectContext(context.Background())
+}
+
+// ReconnectContext is Reconnect, giving up when ctx is done.
+func (c *Ftp) ReconnectContext(ctx context.Context) error {
+	c.reconnecting = true
+	defer func() { c.reconnecting = false }()
+
+	c.textprotoConn.Close()
+	c.broken = true
+	if err := c.connect(ctx); err != nil {
+		c.broken = true
+		return err
+	}
+
+	err := c.restore(ctx)
+	if err != nil {
+		c.broken = true
+	}
+	return err
+}
+
+// restore brings a new control connection to the state of the lost one.
+func (c *Ftp) restore(ctx context.Context) error {
+	if c.explicitTLS {
+		tlsData := c.tlsData
+		if err := c.AuthTLS(c.tlsConfig); err != nil {
+			return err
+		}
+		if !tlsData {
+			if err := c.Prot("C"); err != nil {
+				return err
+			}
+		}
+	}
+
+	if c.user != "" {
+		if err := c.LoginContext(ctx, c.user, c.password); err != nil {
+			return err
+		}
+	}
+	if c.transferType != "" {
+		if _, _, err := c.SendCmdContext(ctx, 200, "TYPE %s", c.transferType); err != nil {
+			return err
+		}
+	}
+	if c.cwd != "" {
+		if _, _, err := c.SendCmdContext(ctx, 250, "CWD %s", c.cwd); err != nil {
+			return err
+		}
+	}
+	if c.hashAlgo != "" {
+		if _, _, err := c.SendCmdContext(ctx, 200, "OPTS HASH %s", c.hashAlgo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureConnected reconnects a session whose control connection was lost, when a policy is set.
+func (c *Ftp) ensureConnected(ctx context.Context) error {
+	if !c.broken || c.retry == nil || c.reconnecting {
+		return nil
+	}
+	return c.ReconnectContext(ctx)
+}
+
+// retryContext runs the idempotent operation op and runs it again after reconnecting
+// when it fails with a lost connection or a transient reply, as the policy allows.
+// Only the outermost operation is retried: the operations it is made of run once, or
+// the attempts and the waits would multiply.
+func (c *Ftp) retryContext(ctx context.Context, op func() error) error {
+	if c.retry == nil || c.retrying {
+		return op()
+	}
+	c.retrying = true
+	defer func() { c.retrying = false }()
+
+	err := op()
+
+	for attempt := 0; attempt < c.retry.MaxRetries && c.retryable(err); attempt++ {
+		if ctx.Err() != nil {
+			return err
+		}
+
+		timer := time.NewTimer(c.retry.backoff(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+
+		if c.broken {
+			if rerr := c.ReconnectContext(ctx); rerr != nil {
+				err = rerr
+				continue
+			}
+		}
+		err = op()
+	}
+	return err
+}
+
+// retryable
+func (c *Ftp) retryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if c.broken {
+		return true
+	}
+	// a 450 about a missing or forbidden file does not change by waiting
+	return errors.Is(err, ErrTransient) && !errors.Is(err, ErrNotFound) && !errors.Is(err, ErrPermission)
+}
+
+// checkConn marks the session broken when err means the control connection is lost:
+// a 421 reply or any error that is not a reply of the server.
+func (c *Ftp) checkConn(err error) {
+	if err == nil {
+		return
+	}
+
+	var e *FtpError
+	if !errors.As(err, &e) || e.Code == StatusNotAvailable {
+		c.broken = true
+	}
+}
+
+// rememberCwd records the working directory to restore after a reconnect.
+func (c *Ftp) rememberCwd() {
+	if c.retry == nil {
+		return
+	}
+	if dir, err := c.Pwd(); err == nil {
+		c.cwd = dir
+	}
+}
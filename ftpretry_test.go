@@ -0,0 +1,109 @@
+package ftpgo
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{context.Canceled, false},
+		{io.EOF, false},
+		{newFtpError(425, "Can't open data connection"), true},
+		{newFtpError(426, "Connection closed; transfer aborted"), true},
+		{newFtpError(450, "Requested file action not taken"), false},
+		{newFtpError(450, "/a: Permission denied"), false},
+		{newFtpError(451, "Local error in processing"), true},
+		{newFtpError(550, "No such file"), false},
+	}
+
+	c := &Ftp{}
+	for _, tt := range tests {
+		if got := c.retryable(tt.err); got != tt.want {
+			t.Errorf("retryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+
+	c.broken = true
+	if !c.retryable(io.EOF) {
+		t.Error("error of a broken session not retried")
+	}
+}
+
+func TestRetryNotFound(t *testing.T) {
+	s := newTestServer(t)
+	s.replies["SIZE"] = "450 /a: No such file"
+	c := s.dial()
+	c.SetRetryPolicy(&RetryPolicy{MaxRetries: 3, InitialBackoff: time.Second})
+
+	start := time.Now()
+	_, err := c.Size("/a")
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Size = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("missing file retried for %v", elapsed)
+	}
+}
+
+// count returns how many times the command line was received.
+func (s *testServer) count(line string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, cmd := range s.cmds {
+		if cmd == line {
+			n++
+		}
+	}
+	return n
+}
+
+func TestRetryNotNested(t *testing.T) {
+	s := newTestServer(t)
+	s.files["/f"] = []byte("0123456789")
+	s.replies["SIZE"] = "451 Local error in processing"
+	c := s.dial()
+	c.SetRetryPolicy(&RetryPolicy{MaxRetries: 2, InitialBackoff: time.Millisecond})
+	local := filepath.Join(t.TempDir(), "f")
+
+	tests := []struct {
+		name string
+		op   func() error
+		want int
+	}{
+		{"Size", func() error {
+			_, err := c.Size("/f")
+			return err
+		}, 3},
+		// SIZE is part of the retried operation
+		{"RetrFileResumeContext", func() error {
+			return c.RetrFileResumeContext(context.Background(), "/f", local)
+		}, 3},
+		// uploads are not retried, nor the SIZE they are made of
+		{"StorFileResume", func() error {
+			return c.StorFileResume(local, "/g")
+		}, 1},
+	}
+	os.WriteFile(local, []byte("01234"), 0644)
+	for _, tt := range tests {
+		s.mu.Lock()
+		s.cmds = nil
+		s.mu.Unlock()
+		if err := tt.op(); !errors.Is(err, ErrTransient) {
+			t.Fatalf("%s = %v", tt.name, err)
+		}
+		if n := s.count("SIZE /f") + s.count("SIZE /g"); n != tt.want {
+			t.Errorf("%s sent SIZE %d times, want %d", tt.name, n, tt.want)
+		}
+	}
+}
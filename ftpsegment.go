@@ -0,0 +1,197 @@
+package ftpgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// minSegmentSize is the smallest range fetched by its own session; smaller files
+// are not worth the extra connections.
+const minSegmentSize = 1024 * 1024
+
+// RetrFileSegmented fetches the remote file over up to segments sessions of the pool at once.
+// The file is split into ranges with SIZE, every range is fetched with REST and RETR and
+// written at its offset of the local file, and the transfer of a range is aborted once it
+// is complete. A single stream is used for small files, ASCII transfers and when the server
+// does not support REST.
+// The progress of the whole file is reported to the ProgressFunc of the transfer options.
+// The bytes written are checked against SIZE, which is issued again at the end to detect
+// a remote file that changed during the download.
+func (p *Pool) RetrFileSegmented(ctx context.Context, remote, local string, segments int, opts ...TransferOption) error {
+	c, err := p.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	size, err := c.Size(remote)
+	if err != nil {
+		p.Release(c)
+		return err
+	}
+	total := int64(size)
+
+	features := c.Features()
+	noRest := len(features) > 0 && !features.Has("REST")
+	if segments > int(total/minSegmentSize) {
+		segments = int(total / minSegmentSize)
+	}
+	if segments <= 1 || noRest || c.asciiTransfer(c.newTransfer(opts), remote) {
+		err = c.RetrFileContext(ctx, remote, local, append(opts[:len(opts):len(opts)], WithTotal(total))...)
+		p.Release(c)
+		return err
+	}
+
+	// the progress of the segments is reported as one transfer
+	t := c.newTransfer(opts)
+	p.Release(c)
+	var progress func(n int)
+	if t.progress != nil {
+		var mu sync.Mutex
+		tracker := newProgressTracker(t.progress, 0, total)
+		progress = func(n int) {
+			mu.Lock()
+			tracker.add(n)
+			mu.Unlock()
+		}
+	}
+	segOpts := append(opts[:len(opts):len(opts)], WithProgress(nil))
+
+	file, err := os.Create(local)
+	if err != nil {
+		return err
+	}
+	if err = file.Truncate(total); err != nil {
+		file.Close()
+		return err
+	}
+
+	segCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	length := (total + int64(segments) - 1) / int64(segments)
+	errs := make([]error, segments)
+	var written int64
+	var wg sync.WaitGroup
+	for i := 0; i < segments; i++ {
+		offset := int64(i) * length
+		n := length
+		if offset+n > total {
+			n = total - offset
+		}
+
+		wg.Add(1)
+		go func(i int, offset, n int64) {
+			defer wg.Done()
+			w := &offsetWriter{w: file, offset: offset, written: &written, progress: progress}
+			errs[i] = p.retrSegment(segCtx, remote, w, offset, n, offset+n == total, segOpts)
+			if errs[i] != nil {
+				cancel()
+			}
+		}(i, offset, n)
+	}
+	wg.Wait()
+
+	err = firstError(errs)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		if isUnsupported(err) && ctx.Err() == nil {
+			// no REST support, fetch the file in one piece
+			return p.Do(ctx, func(c *Ftp) error {
+				return c.RetrFileContext(ctx, remote, local, append(opts[:len(opts):len(opts)], WithTotal(total))...)
+			})
+		}
+		return err
+	}
+
+	// the local file was truncated to total beforehand, so its size proves nothing
+	if written != total {
+		return fmt.Errorf("Size mismatch for %s: %d bytes written, expected %d", local, written, total)
+	}
+	return p.Do(ctx, func(c *Ftp) error {
+		if err := c.verifyRemoteSize(remote, total); err != nil {
+			return err
+		}
+		return c.verifyFileHash(t, local, remote)
+	})
+}
+
+// retrSegment fetches n bytes of remote from offset to w with a session of the pool.
+func (p *Pool) retrSegment(ctx context.Context, remote string, w io.Writer, offset, n int64, last bool, opts []TransferOption) error {
+	c, err := p.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	r, err := c.retrRequestAt(ctx, remote, uint64(offset), opts)
+	if err != nil {
+		if ctx.Err() != nil {
+			// the reply may be left unread
+			p.Discard(c)
+		} else {
+			p.Release(c)
+		}
+		return err
+	}
+
+	_, err = io.CopyN(w, r, n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	switch {
+	case err != nil:
+		r.Close()
+	case last:
+		err = r.Close()
+	default:
+		// the rest of the file belongs to the other segments
+		err = r.Abort()
+	}
+	if err != nil {
+		p.Discard(c)
+		return err
+	}
+
+	p.Release(c)
+	return nil
+}
+
+// offsetWriter writes sequentially to a io.WriterAt from offset.
+type offsetWriter struct {
+	w      io.WriterAt
+	offset int64
+	// written counts the bytes of all the segments
+	written  *int64
+	progress func(n int)
+}
+
+func (w *offsetWriter) Write(buf []byte) (int, error) {
+	n, err := w.w.WriteAt(buf, w.offset)
+	w.offset += int64(n)
+	atomic.AddInt64(w.written, int64(n))
+	if n > 0 && w.progress != nil {
+		w.progress(n)
+	}
+	return n, err
+}
+
+// firstError returns the first error which is not caused by cancelling the others.
+func firstError(errs []error) error {
+	var canceled error
+	for _, err := range errs {
+		switch {
+		case err == nil:
+		case errors.Is(err, context.Canceled):
+			canceled = err
+		default:
+			return err
+		}
+	}
+	return canceled
+}
@@ -0,0 +1,78 @@
+package ftpgo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newSegmentTest returns a server with a file of three segments and a pool of sessions to it.
+func newSegmentTest(t *testing.T) (*testServer, *Pool, []byte) {
+	s := newTestServer(t)
+	data := make([]byte, 3*minSegmentSize+100)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	s.files["/big"] = data
+	p := NewPool(3, func(ctx context.Context) (*Ftp, error) {
+		c, err := FtpConnectContext(ctx, s.ln.Addr().String(), 5*time.Second)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.LoginContext(ctx, "user", "pass"); err != nil {
+			c.Quit()
+			return nil, err
+		}
+		c.SetPasv(true)
+		return c, nil
+	})
+	t.Cleanup(func() { p.Close() })
+	return s, p, data
+}
+
+func TestRetrFileSegmented(t *testing.T) {
+	s, p, data := newSegmentTest(t)
+	local := filepath.Join(t.TempDir(), "big")
+
+	if err := p.RetrFileSegmented(context.Background(), "/big", local, 3); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(local)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("read %d bytes, %v", len(got), err)
+	}
+	if !s.sent("REST 2097220") {
+		t.Fatal("last segment not restarted at its offset")
+	}
+}
+
+func TestRetrFileSegmentedShort(t *testing.T) {
+	s, p, _ := newSegmentTest(t)
+	local := filepath.Join(t.TempDir(), "big")
+	// the file is shorter than announced, so the last segment ends early
+	s.replies["SIZE /big"] = "213 3145900"
+
+	err := p.RetrFileSegmented(context.Background(), "/big", local, 3)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("RetrFileSegmented = %v", err)
+	}
+}
+
+func TestRetrFileSegmentedNoRest(t *testing.T) {
+	s, p, data := newSegmentTest(t)
+	local := filepath.Join(t.TempDir(), "big")
+	s.replies["REST"] = "502 REST not implemented"
+
+	if err := p.RetrFileSegmented(context.Background(), "/big", local, 3); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(local)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("read %d bytes, %v", len(got), err)
+	}
+}
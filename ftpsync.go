@@ -0,0 +1,424 @@
+package ftpgo
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ErrSyncConflict is the error reported for the files a sync cannot decide on,
+// usually because they changed on both sides.
+var ErrSyncConflict = errors.New("Sync conflict")
+
+// SyncOp is what a sync plans to do with a file.
+type SyncOp int
+
+const (
+	// SyncUpload copies the local file to the server.
+	SyncUpload SyncOp = iota
+	// SyncDownload copies the remote file to the local directory.
+	SyncDownload
+	// SyncDeleteLocal deletes the local file, which was deleted on the server.
+	SyncDeleteLocal
+	// SyncDeleteRemote deletes the remote file, which was deleted locally.
+	SyncDeleteRemote
+	// SyncConflict leaves the file alone and reports it with ErrSyncConflict.
+	SyncConflict
+)
+
+// String returns the name of the operation.
+func (op SyncOp) String() string {
+	switch op {
+	case SyncUpload:
+		return "upload"
+	case SyncDownload:
+		return "download"
+	case SyncDeleteLocal:
+		return "delete local"
+	case SyncDeleteRemote:
+		return "delete remote"
+	case SyncConflict:
+		return "conflict"
+	}
+	return "unknown"
+}
+
+// SyncOptions configures a sync. The zero value (or nil) never deletes, lets the newer
+// file win and transfers with as many sessions as the pool allows.
+type SyncOptions struct {
+	// LastSync is the time of the previous sync. A file changed since then on one side
+	// only is copied to the other side, a file changed on both sides is a conflict, and
+	// a file missing on one side is deleted on the other when it did not change since.
+	// The zero time means there was no previous sync: the newer file wins and nothing is deleted.
+	LastSync time.Time
+	// Checksum compares the files of equal size but different modification times
+	// with the checksum of the server (see Checksum) before planning to copy them.
+	// Files the server cannot checksum are compared by modification time only.
+	Checksum bool
+	// Include limits the sync to the files whose name matches one of the patterns; empty includes every file.
+	Include []string
+	// Exclude skips the files and directories whose name matches one of the patterns.
+	Exclude []string
+	// Concurrency is the number of files transferred at once; <= 0 uses the pool size.
+	Concurrency int
+	// TransferOptions are applied to every file transfer.
+	TransferOptions []TransferOption
+	// DryRun makes Sync print the plan to this writer instead of executing it.
+	DryRun io.Writer
+}
+
+// SyncAction is a planned operation on one file.
+type SyncAction struct {
+	Op SyncOp
+	// Path is the slash separated path of the file below the synchronized directories.
+	Path string
+	// Local and Remote describe the file on both sides; nil when it is missing.
+	Local  fs.FileInfo
+	Remote *FtpFile
+	// Reason explains the decision.
+	Reason string
+}
+
+// SyncPlan is the list of operations making a local and a remote tree identical.
+// It can be inspected or printed before it is executed with ExecuteSync.
+type SyncPlan struct {
+	Local   string
+	Remote  string
+	Actions []SyncAction
+	opts    SyncOptions
+}
+
+// WriteTo prints the plan, one action per line.
+func (plan *SyncPlan) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for _, action := range plan.Actions {
+		n, err := fmt.Fprintf(w, "%-13s %s (%s)\n", action.Op, action.Path, action.Reason)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// Sync plans the synchronization of the local and remote directories with PlanSync and
+// executes it with ExecuteSync, or only prints the plan when opts.DryRun is set.
+func (p *Pool) Sync(ctx context.Context, local, remote string, opts *SyncOptions) ([]MirrorResult, error) {
+	plan, err := p.PlanSync(ctx, local, remote, opts)
+	if err != nil {
+		return nil, err
+	}
+	if plan.opts.DryRun != nil {
+		_, err := plan.WriteTo(plan.opts.DryRun)
+		return nil, err
+	}
+	return p.ExecuteSync(ctx, plan)
+}
+
+// PlanSync compares the local and remote trees by size and modification time (to the second,
+// from MLSD or MDTM), and with checksums when opts.Checksum is set, and returns the
+// operations making them identical. Nothing is changed on either side.
+func (p *Pool) PlanSync(ctx context.Context, local, remote string, opts *SyncOptions) (*SyncPlan, error) {
+	if opts == nil {
+		opts = &SyncOptions{}
+	}
+	plan := &SyncPlan{Local: local, Remote: remote, opts: *opts}
+
+	localFiles, err := listLocalTree(local, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.Do(ctx, func(c *Ftp) error {
+		remoteFiles, err := c.listRemoteTree(ctx, remote, opts)
+		if err != nil {
+			return err
+		}
+
+		paths := make([]string, 0, len(localFiles)+len(remoteFiles))
+		for rel := range localFiles {
+			paths = append(paths, rel)
+		}
+		for rel := range remoteFiles {
+			if _, ok := localFiles[rel]; !ok {
+				paths = append(paths, rel)
+			}
+		}
+		sort.Strings(paths)
+
+		for _, rel := range paths {
+			action, err := c.planFile(rel, localFiles[rel], remoteFiles[rel], plan)
+			if err != nil {
+				return err
+			}
+			if action != nil {
+				plan.Actions = append(plan.Actions, *action)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// planFile decides what to do with the file at rel; nil means nothing.
+func (c *Ftp) planFile(rel string, local fs.FileInfo, remote *FtpFile, plan *SyncPlan) (*SyncAction, error) {
+	action := &SyncAction{Path: rel, Local: local, Remote: remote}
+	lastSync := plan.opts.LastSync.Truncate(time.Second)
+
+	if remote == nil {
+		localTime := local.ModTime().Truncate(time.Second)
+		if lastSync.IsZero() || localTime.After(lastSync) {
+			action.Op, action.Reason = SyncUpload, "new local file"
+		} else {
+			action.Op, action.Reason = SyncDeleteLocal, "deleted on the server"
+		}
+		return action, nil
+	}
+
+	remoteTime := remote.mtime.Truncate(time.Second)
+	if local == nil {
+		if lastSync.IsZero() || remoteTime.After(lastSync) {
+			action.Op, action.Reason = SyncDownload, "new remote file"
+		} else {
+			action.Op, action.Reason = SyncDeleteRemote, "deleted locally"
+		}
+		return action, nil
+	}
+
+	localTime := local.ModTime().Truncate(time.Second)
+	if local.Size() == remote.size {
+		if localTime.Equal(remoteTime) {
+			return nil, nil
+		}
+		if plan.opts.Checksum {
+			same, err := c.sameChecksum(filepath.Join(plan.Local, filepath.FromSlash(rel)), path.Join(plan.Remote, rel))
+			if err != nil {
+				return nil, err
+			}
+			if same {
+				return nil, nil
+			}
+		}
+	}
+
+	if lastSync.IsZero() {
+		switch {
+		case localTime.After(remoteTime):
+			action.Op, action.Reason = SyncUpload, "local file is newer"
+		case remoteTime.After(localTime):
+			action.Op, action.Reason = SyncDownload, "remote file is newer"
+		default:
+			action.Op, action.Reason = SyncConflict, "same time, different contents"
+		}
+		return action, nil
+	}
+
+	localChanged, remoteChanged := localTime.After(lastSync), remoteTime.After(lastSync)
+	switch {
+	case localChanged && remoteChanged:
+		action.Op, action.Reason = SyncConflict, "changed on both sides"
+	case localChanged:
+		action.Op, action.Reason = SyncUpload, "changed locally"
+	case remoteChanged:
+		action.Op, action.Reason = SyncDownload, "changed on the server"
+	default:
+		action.Op, action.Reason = SyncConflict, "differ without a change since the last sync"
+	}
+	return action, nil
+}
+
+// sameChecksum compares a local file with the checksum of the remote one. A server which
+// cannot checksum the file makes the files different; only a lost connection is an error.
+func (c *Ftp) sameChecksum(local, remote string) (bool, error) {
+	algo, err := c.hashAlgorithm("")
+	if err != nil {
+		return false, nil
+	}
+	remoteSum, err := c.Checksum(remote, algo)
+	if err != nil {
+		if c.broken {
+			return false, err
+		}
+		return false, nil
+	}
+
+	file, err := os.Open(local)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	h := newHash(algo)
+	if _, err := io.Copy(h, file); err != nil {
+		return false, err
+	}
+	return hex.EncodeToString(h.Sum(nil)) == remoteSum, nil
+}
+
+// ExecuteSync runs the operations of a plan, transferring several files at once with the
+// sessions of the pool. Conflicts are left alone and reported as failed with ErrSyncConflict.
+// The returned report lists every action; the error is only non-nil when ctx is done.
+func (p *Pool) ExecuteSync(ctx context.Context, plan *SyncPlan) ([]MirrorResult, error) {
+	var results []MirrorResult
+	var jobs []*mirrorJob
+	ops := make(map[*mirrorJob]SyncOp)
+	for _, action := range plan.Actions {
+		if action.Op == SyncConflict {
+			results = append(results, MirrorResult{Path: action.Path, Action: MirrorFailed,
+				Err: fmt.Errorf("%w: %s: %s", ErrSyncConflict, action.Path, action.Reason)})
+			continue
+		}
+
+		job := &mirrorJob{
+			rel:    action.Path,
+			remote: path.Join(plan.Remote, action.Path),
+			local:  filepath.Join(plan.Local, filepath.FromSlash(action.Path)),
+			info:   action.Remote,
+		}
+		switch {
+		case action.Op == SyncUpload || action.Op == SyncDeleteLocal:
+			job.size = action.Local.Size()
+		default:
+			job.size = action.Remote.size
+		}
+		jobs = append(jobs, job)
+		ops[job] = action.Op
+	}
+
+	if err := p.Do(ctx, func(c *Ftp) error {
+		return c.makeRemoteDirs(plan, jobs, ops)
+	}); err != nil {
+		return nil, err
+	}
+
+	opts := plan.opts.TransferOptions
+	jobResults := p.runMirror(ctx, jobs, plan.opts.Concurrency, func(c *Ftp, job *mirrorJob) (MirrorAction, error) {
+		switch ops[job] {
+		case SyncUpload:
+			return copyAction(c.mirrorUpFile(ctx, job, opts))
+		case SyncDownload:
+			if err := os.MkdirAll(filepath.Dir(job.local), 0755); err != nil {
+				return MirrorFailed, err
+			}
+			return copyAction(c.mirrorFile(ctx, job, opts))
+		case SyncDeleteLocal:
+			return MirrorDeleted, os.Remove(job.local)
+		default:
+			return MirrorDeleted, c.Delete(job.remote)
+		}
+	})
+	return append(results, jobResults...), ctx.Err()
+}
+
+// makeRemoteDirs creates the remote directories the uploads of a plan need.
+func (c *Ftp) makeRemoteDirs(plan *SyncPlan, jobs []*mirrorJob, ops map[*mirrorJob]SyncOp) error {
+	dirs := make(map[string]bool)
+	for _, job := range jobs {
+		if ops[job] == SyncUpload {
+			dirs[path.Dir(job.remote)] = true
+		}
+	}
+
+	for dir := range dirs {
+		root, name := splitRemoteRoot(dir)
+		if err := NewFtpFS(c, root).MkdirAll(name, 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listLocalTree returns the regular files below the local directory by slash separated path.
+func listLocalTree(local string, opts *SyncOptions) (map[string]fs.FileInfo, error) {
+	files := make(map[string]fs.FileInfo)
+	err := filepath.WalkDir(local, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name == local {
+			return nil
+		}
+		if FileMatch(entry.Name(), opts.Exclude...) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() || (len(opts.Include) > 0 && !FileMatch(entry.Name(), opts.Include...)) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(local, name)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = info
+		return nil
+	})
+	return files, err
+}
+
+// listRemoteTree returns the regular files below the remote directory by slash separated path,
+// with their modification time to the second. A missing remote directory is empty.
+func (c *Ftp) listRemoteTree(ctx context.Context, remote string, opts *SyncOptions) (map[string]*FtpFile, error) {
+	files := make(map[string]*FtpFile)
+
+	var walk func(rel string) error
+	walk = func(rel string) error {
+		dir := path.Join(remote, rel)
+		infos, err := c.DirContext(ctx, dir)
+		if err != nil {
+			if rel == "" && errors.Is(err, ErrNotFound) {
+				// nothing was synced yet
+				return nil
+			}
+			// a partial tree would make the missing files look deleted
+			return err
+		}
+
+		for _, info := range infos {
+			name := path.Base(info.name)
+			if name == "." || name == ".." || FileMatch(name, opts.Exclude...) {
+				continue
+			}
+			child := path.Join(rel, name)
+			switch {
+			case info.IsDir():
+				if err := walk(child); err != nil {
+					return err
+				}
+			case info.mode.IsRegular():
+				if len(opts.Include) > 0 && !FileMatch(name, opts.Include...) {
+					continue
+				}
+				mtime, err := c.modTime(path.Join(dir, name), info)
+				if err != nil {
+					return err
+				}
+				info.name = name
+				if !mtime.IsZero() {
+					info.mtime = mtime
+				}
+				files[child] = info
+			}
+		}
+		return nil
+	}
+
+	return files, walk("")
+}
@@ -0,0 +1,79 @@
+package ftpgo
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestListRemoteTreeMissingDirectory(t *testing.T) {
+	s := newTestServer(t)
+	s.lists["/r"] = "drwxr-xr-x  2 ftp ftp 4096 Jan 02 2020 sub\r\n" +
+		"-rw-r--r--  1 ftp ftp   12 Jan 02 2020 a.txt\r\n"
+	c := s.dial()
+	ctx := context.Background()
+
+	// the root may not exist yet
+	files, err := c.listRemoteTree(ctx, "/missing", &SyncOptions{})
+	if err != nil || len(files) != 0 {
+		t.Fatalf("missing root = %v, %v", files, err)
+	}
+
+	// a subdirectory which cannot be listed fails the whole listing
+	if files, err := c.listRemoteTree(ctx, "/r", &SyncOptions{}); err == nil {
+		t.Fatalf("missing subdirectory = %v", files)
+	}
+
+	s.mu.Lock()
+	s.lists["/r/sub"] = "-rw-r--r--  1 ftp ftp   3 Jan 02 2020 b.txt\r\n"
+	s.mu.Unlock()
+	files, err = c.listRemoteTree(ctx, "/r", &SyncOptions{})
+	if err != nil || len(files) != 2 || files["a.txt"] == nil || files["sub/b.txt"] == nil {
+		t.Fatalf("tree = %v, %v", files, err)
+	}
+}
+
+func TestPlanSyncChecksumUnsupported(t *testing.T) {
+	local := t.TempDir()
+	if err := os.WriteFile(filepath.Join(local, "a.txt"), []byte("aaa"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum := md5.Sum([]byte("aaa"))
+
+	tests := []struct {
+		name  string
+		feat  []string
+		reply string
+		want  string
+	}{
+		{"no checksum command", nil, "", "upload"},
+		{"checksum refused", []string{"XMD5"}, "550 /r/a.txt: Permission denied", "upload"},
+		{"same checksum", []string{"XMD5"}, "250 " + hex.EncodeToString(sum[:]), ""},
+	}
+	for _, tt := range tests {
+		s := newTestServer(t, append([]string{"MLST type*;size*;modify*;"}, tt.feat...)...)
+		s.dirs["/r"] = true
+		s.files["/r/a.txt"] = []byte("bbb")
+		if tt.reply != "" {
+			s.replies["XMD5 /r/a.txt"] = tt.reply
+		}
+		p, _ := newTestPool(s, 1)
+
+		// same size, different modification times
+		plan, err := p.PlanSync(context.Background(), local, "/r", &SyncOptions{Checksum: true})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var ops []string
+		for _, action := range plan.Actions {
+			ops = append(ops, action.Op.String())
+		}
+		if strings.Join(ops, " ") != tt.want {
+			t.Errorf("%s: plan %v, want %q", tt.name, ops, tt.want)
+		}
+	}
+}
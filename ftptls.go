@@ -0,0 +1,118 @@
+package ftpgo
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/textproto"
+	"time"
+)
+
+// FtpConnectExplicitTLS Connect to server and secure the session with AUTH TLS (explicit FTPS).
+// A nil config uses the default TLS settings with the server name taken from addr.
+func FtpConnectExplicitTLS(addr string, timeout time.Duration, config *tls.Config) (*Ftp, error) {
+	c, err := FtpConnect(addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = c.AuthTLS(config); err != nil {
+		c.Quit()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// FtpConnectImplicitTLS Connect to server with implicit FTPS (usually port 990).
+// The TLS handshake is performed before the 220 greeting and every data connection is protected.
+func FtpConnectImplicitTLS(addr string, timeout time.Duration, config *tls.Config) (*Ftp, error) {
+	c := &Ftp{addr: addr, timeout: timeout, implicitTLS: true}
+	c.tlsConfig = c.makeTLSConfig(config)
+	if err := c.connect(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// AuthTLS issues an AUTH TLS FTP command and upgrades the control connection to TLS.
+// On success PBSZ 0 and PROT P are sent so that every data connection is protected as well.
+func (c *Ftp) AuthTLS(config *tls.Config) error {
+	_, _, err := c.SendCmd(234, "AUTH TLS")
+	if err != nil {
+		return err
+	}
+
+	c.tlsConfig = c.makeTLSConfig(config)
+	conn, err := c.tlsHandshake(c.conn)
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+	c.textprotoConn = textproto.NewConn(conn)
+	c.explicitTLS = true
+
+	if err = c.Pbsz(0); err != nil {
+		return err
+	}
+	return c.Prot("P")
+}
+
+// Pbsz issues a PBSZ FTP command to set the protection buffer size. TLS requires a size of 0.
+func (c *Ftp) Pbsz(size int) error {
+	_, _, err := c.SendCmd(200, "PBSZ %d", size)
+	return err
+}
+
+// Prot issues a PROT FTP command to set the data channel protection level.
+// "P" (private) wraps the data connections in TLS, "C" (clear) leaves them unprotected.
+func (c *Ftp) Prot(level string) error {
+	_, _, err := c.SendCmd(200, "PROT %s", level)
+	if err != nil {
+		return err
+	}
+
+	c.tlsData = level == "P" && c.tlsConfig != nil
+	return nil
+}
+
+// makeTLSConfig copies the caller's config and fills in what the data connections need:
+// the server name used for verification and a session cache, because many servers
+// require the data channel to resume the TLS session of the control connection.
+func (c *Ftp) makeTLSConfig(config *tls.Config) *tls.Config {
+	if config == nil {
+		config = &tls.Config{}
+	} else {
+		config = config.Clone()
+	}
+
+	if config.ServerName == "" {
+		host, _, err := net.SplitHostPort(c.addr)
+		if err != nil {
+			host = c.addr
+		}
+		config.ServerName = host
+	}
+	if config.ClientSessionCache == nil {
+		config.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+	}
+
+	return config
+}
+
+// tlsHandshake wraps conn in a TLS client and completes the handshake within the session timeout.
+func (c *Ftp) tlsHandshake(conn net.Conn) (net.Conn, error) {
+	tlsConn := tls.Client(conn, c.tlsConfig)
+	if c.timeout > 0 {
+		tlsConn.SetDeadline(time.Now().Add(c.timeout))
+	}
+
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	tlsConn.SetDeadline(time.Time{})
+	return tlsConn, nil
+}
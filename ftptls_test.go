@@ -0,0 +1,190 @@
+package ftpgo
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useTLS makes the server accept AUTH TLS, or only implicit TLS sessions,
+// and returns a client config trusting its certificate.
+func (s *testServer) useTLS(implicit bool) *tls.Config {
+	server, client := newTestTLS(s.t)
+	s.mu.Lock()
+	s.tls, s.implicit = server, implicit
+	s.mu.Unlock()
+	return client
+}
+
+// newTestTLS returns a server config with a self-signed certificate for 127.0.0.1,
+// and a client config trusting it.
+func newTestTLS(t *testing.T) (server, client *tls.Config) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "ftpgo test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	server = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	return server, &tls.Config{RootCAs: pool}
+}
+
+func TestExplicitTLS(t *testing.T) {
+	s := newTestServer(t)
+	config := s.useTLS(false)
+	s.files["/f"] = []byte("secret data")
+
+	c, err := FtpConnectExplicitTLS(s.ln.Addr().String(), 5*time.Second, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Quit()
+	if err := c.Login("user", "pass"); err != nil {
+		t.Fatal(err)
+	}
+	c.SetPasv(true)
+	for _, cmd := range []string{"AUTH TLS", "PBSZ 0", "PROT P"} {
+		if !s.sent(cmd) {
+			t.Fatalf("%s not sent", cmd)
+		}
+	}
+	if _, ok := c.conn.(*tls.Conn); !ok {
+		t.Fatalf("control connection is %T", c.conn)
+	}
+
+	// the server only completes the transfers over TLS
+	r, err := c.RetrRequest("/f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	if cerr := r.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil || string(data) != "secret data" {
+		t.Fatalf("RETR = %q, %v", data, err)
+	}
+
+	w, err := c.StorRequest("/g")
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(w, "uploaded")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	stored := string(s.files["/g"])
+	s.mu.Unlock()
+	if stored != "uploaded" {
+		t.Fatalf("STOR = %q", stored)
+	}
+
+	// PROT C leaves the data connections in clear text
+	s.mu.Lock()
+	s.lists["/"] = "f\r\n"
+	s.mu.Unlock()
+	if err := c.Prot("C"); err != nil || c.tlsData {
+		t.Fatalf("PROT C = %v, data protected %v", err, c.tlsData)
+	}
+	if lines, err := c.Nlst("/"); err != nil || len(lines) != 1 {
+		t.Fatalf("clear NLST = %q, %v", lines, err)
+	}
+}
+
+func TestExplicitTLSFailures(t *testing.T) {
+	s := newTestServer(t)
+	if _, err := FtpConnectExplicitTLS(s.ln.Addr().String(), 5*time.Second, nil); err == nil {
+		t.Fatal("AUTH TLS succeeded on a server without TLS")
+	}
+
+	// the certificate is not trusted
+	s.useTLS(false)
+	_, err := FtpConnectExplicitTLS(s.ln.Addr().String(), 5*time.Second, nil)
+	if err == nil || !strings.Contains(err.Error(), "certificate") {
+		t.Fatalf("untrusted certificate: %v", err)
+	}
+}
+
+func TestImplicitTLS(t *testing.T) {
+	s := newTestServer(t)
+	config := s.useTLS(true)
+	s.files["/f"] = []byte("secret data")
+
+	c, err := FtpConnectImplicitTLS(s.ln.Addr().String(), 5*time.Second, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Quit()
+	if err := c.Login("user", "pass"); err != nil {
+		t.Fatal(err)
+	}
+	c.SetPasv(true)
+	if s.sent("AUTH TLS") || !s.sent("PROT P") || !c.tlsData {
+		t.Fatal("implicit session not negotiated")
+	}
+	r, err := c.RetrRequest("/f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	if cerr := r.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil || string(data) != "secret data" {
+		t.Fatalf("RETR = %q, %v", data, err)
+	}
+
+	// a server without PBSZ and PROT keeps the data connections private
+	s.mu.Lock()
+	s.replies["PBSZ"] = "502 not implemented"
+	s.mu.Unlock()
+	c, err = FtpConnectImplicitTLS(s.ln.Addr().String(), 5*time.Second, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Quit()
+	if !c.tlsData {
+		t.Fatal("data connections not protected")
+	}
+
+	// a refused PROT P fails the connection instead of the transfers
+	s.mu.Lock()
+	delete(s.replies, "PBSZ")
+	s.replies["PROT"] = "536 protection level not supported"
+	s.mu.Unlock()
+	if c, err := FtpConnectImplicitTLS(s.ln.Addr().String(), 5*time.Second, config); err == nil {
+		c.Quit()
+		t.Fatal("connected with PROT P refused")
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/kzdev/ftpgo
+
+go 1.16